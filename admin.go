@@ -0,0 +1,473 @@
+package main
+
+import (
+	"FORUM-GO/configAPI"
+	"FORUM-GO/databaseAPI"
+	"FORUM-GO/storageAPI"
+	"bufio"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseAdminFlags parses the flags of an admin subcommand, which takes nargs arguments, loads the configuration and
+// opens the database, whose schema must be up to date: only migrate migrates it. It returns false with the exit code if
+// the subcommand can't run, otherwise the database must be closed. Only create opens a database that doesn't exist yet,
+// and creates its tables.
+func parseAdminFlags(flags *flag.FlagSet, configFlags *configAPI.Flags, args []string, nargs int, create bool) (int, bool) {
+	if err := flags.Parse(args); err != nil {
+		return 2, false
+	}
+	if flags.NArg() != nargs {
+		flags.Usage()
+		return 2, false
+	}
+	if !loadConfig(configFlags) {
+		return 2, false
+	}
+	if _, err := os.Stat(config.Database.Path); err != nil && !create && config.Database.Driver == "sqlite" {
+		fmt.Println("Unable to open the database: " + err.Error())
+		return 1, false
+	}
+	if err := connectDatabase(); err != nil {
+		fmt.Println("Unable to open the database: " + err.Error())
+		return 1, false
+	}
+	empty, err := databaseAPI.IsEmpty(database)
+	if err == nil {
+		if create && empty {
+			err = initDatabase()
+		} else {
+			err = databaseAPI.CheckSchemaUpToDate(database)
+		}
+	}
+	if err != nil {
+		database.Close()
+		fmt.Println("Unable to open the database: " + err.Error())
+		return 1, false
+	}
+	return 0, true
+}
+
+// newAdminFlags returns the flags of an admin subcommand, with its usage
+func newAdminFlags(name string, usage ...string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		for _, line := range usage {
+			fmt.Fprintln(flags.Output(), line)
+		}
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// runSubcommand runs the subcommand of a command named by the first argument, and returns the exit code
+func runSubcommand(command string, args []string, subcommands map[string]func([]string) int, names string) int {
+	if len(args) > 0 {
+		if run, ok := subcommands[args[0]]; ok {
+			return run(args[1:])
+		}
+	}
+	fmt.Println("Usage: forum " + command + " " + names + " [flags] ...")
+	return 2
+}
+
+// readPassword reads a password from the first line of the standard input, so that it isn't kept in the shell history
+func readPassword() (string, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Print("Password: ")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", errors.New("no password given on the standard input")
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("the password is empty")
+	}
+	return password, nil
+}
+
+// runMigrate runs the migrate subcommand, which creates the missing tables and columns of the database and saves its
+// schema version, as the server does when it starts, and returns the exit code
+func runMigrate(args []string) int {
+	flags := newAdminFlags("migrate", "Usage: forum migrate [flags]")
+	configFlags := configAPI.AddFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+	if !loadConfig(configFlags) {
+		return 2
+	}
+	previous := 0
+	if _, err := os.Stat(config.Database.Path); err == nil || config.Database.Driver == "postgres" {
+		driver, source := databaseAPI.ObservedDriver, config.Database.Path
+		if config.Database.Driver == "postgres" {
+			driver, source = databaseAPI.ObservedPostgresDriver, config.Database.URL
+		}
+		existing, err := sql.Open(driver, source)
+		if err == nil {
+			previous, err = databaseAPI.GetSchemaVersion(existing)
+			existing.Close()
+		}
+		if err != nil {
+			fmt.Println("Unable to read the schema version: " + err.Error())
+			return 1
+		}
+	}
+	if err := openDatabase(); err != nil {
+		fmt.Println("Migration failed: " + err.Error())
+		return 1
+	}
+	defer database.Close()
+	if previous == databaseAPI.SchemaVersion {
+		fmt.Println("Database " + databaseName() + " is up to date, schema version " + strconv.Itoa(previous))
+		return 0
+	}
+	fmt.Println("Database " + databaseName() + " migrated from schema version " + strconv.Itoa(previous) + " to " + strconv.Itoa(databaseAPI.SchemaVersion))
+	return 0
+}
+
+// runUser runs the user subcommands, which manage the accounts, and returns the exit code
+func runUser(args []string) int {
+	return runSubcommand("user", args, map[string]func([]string) int{
+		"create":         runUserCreate,
+		"promote":        runUserPromote,
+		"ban":            runUserBan,
+		"reset-password": runUserResetPassword,
+	}, "create|promote|ban|reset-password")
+}
+
+// runUserCreate creates an account with a password read from the standard input
+func runUserCreate(args []string) int {
+	flags := newAdminFlags("user create", "Usage: forum user create [flags] username email",
+		"The password is read from the standard input. Accounts are created as plain users unless -admin is given.")
+	admin := flags.Bool("admin", false, "make the user an admin")
+	configFlags := configAPI.AddFlags(flags)
+	if code, ok := parseAdminFlags(flags, configFlags, args, 2, true); !ok {
+		return code
+	}
+	defer database.Close()
+	username, email := flags.Arg(0), flags.Arg(1)
+	if username == "" || email == "" || username == databaseAPI.DeletedUsername {
+		fmt.Println("Invalid username or email")
+		return 2
+	}
+	password, err := readPassword()
+	if err != nil {
+		fmt.Println("Unable to create " + username + ": " + err.Error())
+		return 2
+	}
+	if err := store.AddUser(username, email, password, "", ""); err != nil {
+		if errors.Is(err, databaseAPI.ErrConflict) {
+			fmt.Println("Unable to create " + username + ": the username or the email is taken")
+			return 1
+		}
+		fmt.Println("Unable to create " + username + ": " + err.Error())
+		return 1
+	}
+	if *admin {
+		if err := databaseAPI.SetRole(database, username, databaseAPI.RoleAdmin); err != nil {
+			fmt.Println("Unable to make " + username + " an admin: " + err.Error())
+			return 1
+		}
+	}
+	isAdmin, _ := store.IsAdmin(username)
+	if isAdmin {
+		fmt.Println("Admin " + username + " created")
+	} else {
+		fmt.Println("User " + username + " created")
+	}
+	return 0
+}
+
+// runUserPromote makes a user an admin, or a user again with -revoke
+func runUserPromote(args []string) int {
+	flags := newAdminFlags("user promote", "Usage: forum user promote [flags] username")
+	revoke := flags.Bool("revoke", false, "make the admin a user again")
+	configFlags := configAPI.AddFlags(flags)
+	if code, ok := parseAdminFlags(flags, configFlags, args, 1, false); !ok {
+		return code
+	}
+	defer database.Close()
+	username := flags.Arg(0)
+	if err := databaseAPI.SetAdmin(database, username, !*revoke); err != nil {
+		if errors.Is(err, databaseAPI.ErrBanned) {
+			fmt.Println(username + " is banned, lift the ban with forum user ban -lift first")
+			return 1
+		}
+		return printUserError(username, err)
+	}
+	if *revoke {
+		fmt.Println(username + " is no longer an admin")
+	} else {
+		fmt.Println(username + " is now an admin")
+	}
+	return 0
+}
+
+// runUserBan bans a user and logs them out, or lifts their ban with -lift
+func runUserBan(args []string) int {
+	flags := newAdminFlags("user ban", "Usage: forum user ban [flags] username",
+		"A banned user is logged out and can't log in anymore, their posts and comments stay.")
+	lift := flags.Bool("lift", false, "lift the ban, the user gets back the rights of a user")
+	configFlags := configAPI.AddFlags(flags)
+	if code, ok := parseAdminFlags(flags, configFlags, args, 1, false); !ok {
+		return code
+	}
+	defer database.Close()
+	username := flags.Arg(0)
+	if *lift {
+		banned, err := databaseAPI.IsBanned(database, username)
+		if err != nil {
+			return printUserError(username, err)
+		}
+		if !banned {
+			// an admin would lose their role
+			fmt.Println(username + " isn't banned")
+			return 1
+		}
+		if err := databaseAPI.SetRole(database, username, databaseAPI.RoleUser); err != nil {
+			return printUserError(username, err)
+		}
+		fmt.Println(username + " is no longer banned")
+		return 0
+	}
+	if err := databaseAPI.SetRole(database, username, databaseAPI.RoleBanned); err != nil {
+		return printUserError(username, err)
+	}
+	if err := databaseAPI.Logout(database, username); err != nil {
+		return printUserError(username, err)
+	}
+	fmt.Println(username + " is banned")
+	return 0
+}
+
+// runUserResetPassword sets the password of a user, read from the standard input, and logs them out
+func runUserResetPassword(args []string) int {
+	flags := newAdminFlags("user reset-password", "Usage: forum user reset-password [flags] username",
+		"The new password is read from the standard input, the user is logged out.")
+	configFlags := configAPI.AddFlags(flags)
+	if code, ok := parseAdminFlags(flags, configFlags, args, 1, false); !ok {
+		return code
+	}
+	defer database.Close()
+	username := flags.Arg(0)
+	if _, err := store.GetUserEmail(username); err != nil {
+		return printUserError(username, err)
+	}
+	password, err := readPassword()
+	if err != nil {
+		fmt.Println("Unable to reset the password of " + username + ": " + err.Error())
+		return 2
+	}
+	if err := databaseAPI.SetPassword(database, username, password); err != nil {
+		return printUserError(username, err)
+	}
+	fmt.Println("Password of " + username + " reset")
+	return 0
+}
+
+// printUserError prints why a user subcommand failed, and returns the exit code
+func printUserError(username string, err error) int {
+	if errors.Is(err, databaseAPI.ErrNotFound) {
+		fmt.Println("No user " + username)
+	} else {
+		fmt.Println("Unable to change " + username + ": " + err.Error())
+	}
+	return 1
+}
+
+// runCategory runs the category subcommands, which add and remove categories, and returns the exit code
+func runCategory(args []string) int {
+	return runSubcommand("category", args, map[string]func([]string) int{
+		"add":    runCategoryAdd,
+		"remove": runCategoryRemove,
+	}, "add|remove")
+}
+
+// runCategoryAdd adds a category
+func runCategoryAdd(args []string) int {
+	flags := newAdminFlags("category add", "Usage: forum category add [flags] name")
+	icon := flags.String("icon", "", "Font Awesome 4 icon of the category, such as fa-music (default fa-folder)")
+	configFlags := configAPI.AddFlags(flags)
+	if code, ok := parseAdminFlags(flags, configFlags, args, 1, false); !ok {
+		return code
+	}
+	defer database.Close()
+	name := flags.Arg(0)
+	if name == "" || strings.Contains(name, ",") {
+		fmt.Println("Invalid category " + name + ", a name can't be empty or contain a comma")
+		return 2
+	}
+	if err := databaseAPI.AddCategory(database, name, *icon); err != nil {
+		if errors.Is(err, databaseAPI.ErrConflict) {
+			fmt.Println("Category " + name + " already exists")
+		} else {
+			fmt.Println("Unable to add " + name + ": " + err.Error())
+		}
+		return 1
+	}
+	fmt.Println("Category " + name + " added")
+	return 0
+}
+
+// runCategoryRemove removes a category, moving its posts to another one
+func runCategoryRemove(args []string) int {
+	flags := newAdminFlags("category remove", "Usage: forum category remove [flags] name",
+		"A category with posts can only be removed by moving its posts to another category.")
+	moveTo := flags.String("move-to", "", "category to move the posts and the followers of the category to")
+	configFlags := configAPI.AddFlags(flags)
+	if code, ok := parseAdminFlags(flags, configFlags, args, 1, false); !ok {
+		return code
+	}
+	defer database.Close()
+	name := flags.Arg(0)
+	if *moveTo == name {
+		fmt.Println("Unable to move the posts of " + name + " to itself")
+		return 2
+	}
+	count, err := databaseAPI.CountPostsInCategory(database, name)
+	if err != nil {
+		fmt.Println("Unable to remove " + name + ": " + err.Error())
+		return 1
+	}
+	err = databaseAPI.RemoveCategory(database, name, *moveTo)
+	switch {
+	case errors.Is(err, databaseAPI.ErrNotFound):
+		fmt.Println("No category " + name)
+		return 1
+	case errors.Is(err, databaseAPI.ErrConstraint) && *moveTo == "":
+		fmt.Println("Category " + name + " has " + strconv.Itoa(count) + " posts, move them to another category with -move-to")
+		return 1
+	case errors.Is(err, databaseAPI.ErrConstraint):
+		fmt.Println("No category " + *moveTo)
+		return 1
+	case err != nil:
+		fmt.Println("Unable to remove " + name + ": " + err.Error())
+		return 1
+	}
+	if *moveTo != "" {
+		fmt.Println("Category " + name + " removed, its " + strconv.Itoa(count) + " posts moved to " + *moveTo)
+	} else {
+		fmt.Println("Category " + name + " removed")
+	}
+	return 0
+}
+
+// runPost runs the post subcommands, and returns the exit code
+func runPost(args []string) int {
+	return runSubcommand("post", args, map[string]func([]string) int{
+		"delete": runPostDelete,
+	}, "delete")
+}
+
+// runPostDelete deletes a post with its comments, votes and attachments
+func runPostDelete(args []string) int {
+	flags := newAdminFlags("post delete", "Usage: forum post delete [flags] id",
+		"The post is deleted with its comments, votes, attachments, and the notifications and reports about them.")
+	configFlags := configAPI.AddFlags(flags)
+	if code, ok := parseAdminFlags(flags, configFlags, args, 1, false); !ok {
+		return code
+	}
+	defer database.Close()
+	id, err := strconv.Atoi(flags.Arg(0))
+	if err != nil {
+		fmt.Println("Invalid post id " + flags.Arg(0))
+		return 2
+	}
+	post, err := store.GetPost(strconv.Itoa(id))
+	if errors.Is(err, databaseAPI.ErrNotFound) {
+		fmt.Println("No post " + strconv.Itoa(id))
+		return 1
+	}
+	if err != nil {
+		fmt.Println("Unable to delete the post " + strconv.Itoa(id) + ": " + err.Error())
+		return 1
+	}
+	unusedBlobs, err := databaseAPI.DeletePost(database, id)
+	if err != nil {
+		fmt.Println("Unable to delete the post " + strconv.Itoa(id) + ": " + err.Error())
+		return 1
+	}
+	fmt.Println("Post " + strconv.Itoa(id) + " \"" + post.Title + "\" by " + post.Username + " deleted")
+	if len(unusedBlobs) == 0 {
+		return 0
+	}
+	blobStore, err := storageAPI.NewLocalStore(config.Uploads.Dir)
+	if err != nil {
+		fmt.Println("Unable to delete the attached files: " + err.Error())
+		return 1
+	}
+	code := 0
+	for _, key := range unusedBlobs {
+		if err := blobStore.Delete(key); err != nil && !errors.Is(err, storageAPI.ErrNotFound) {
+			fmt.Println("Unable to delete the attached file " + key + ": " + err.Error())
+			code = 1
+		}
+	}
+	return code
+}
+
+// runRecountVotes runs the recount-votes subcommand, which sets the votes counts of the posts from their votes, and
+// returns the exit code
+func runRecountVotes(args []string) int {
+	flags := newAdminFlags("recount-votes", "Usage: forum recount-votes [flags]")
+	configFlags := configAPI.AddFlags(flags)
+	if code, ok := parseAdminFlags(flags, configFlags, args, 0, false); !ok {
+		return code
+	}
+	defer database.Close()
+	count, err := databaseAPI.RecountVotes(database)
+	if err != nil {
+		fmt.Println("Unable to recount the votes: " + err.Error())
+		return 1
+	}
+	fmt.Println("Votes recounted, the counts of " + strconv.Itoa(count) + " posts were wrong")
+	return 0
+}
+
+// runStats runs the stats subcommand, which prints the numbers of users, posts and more of the forum, and returns the
+// exit code
+func runStats(args []string) int {
+	flags := newAdminFlags("stats", "Usage: forum stats [flags]")
+	configFlags := configAPI.AddFlags(flags)
+	if code, ok := parseAdminFlags(flags, configFlags, args, 0, false); !ok {
+		return code
+	}
+	defer database.Close()
+	stats, err := databaseAPI.GetStats(database, time.Now())
+	if err != nil {
+		fmt.Println("Unable to read the stats: " + err.Error())
+		return 1
+	}
+	if config.Database.Driver == "postgres" {
+		fmt.Printf("%-16s %s, schema version %d\n", "Database", databaseName(), stats.SchemaVersion)
+	} else {
+		var size int64
+		if info, err := os.Stat(config.Database.Path); err == nil {
+			size = info.Size()
+		}
+		fmt.Printf("%-16s %s, %d MB, schema version %d\n", "Database", config.Database.Path, size>>20, stats.SchemaVersion)
+	}
+	fmt.Printf("%-16s %d, %d admins, %d banned\n", "Users", stats.Users, stats.Admins, stats.Banned)
+	fmt.Printf("%-16s %d\n", "Active sessions", stats.ActiveSessions)
+	fmt.Printf("%-16s %d\n", "Categories", stats.Categories)
+	fmt.Printf("%-16s %d\n", "Posts", stats.Posts)
+	fmt.Printf("%-16s %d\n", "Comments", stats.Comments)
+	fmt.Printf("%-16s %d\n", "Votes", stats.Votes)
+	fmt.Printf("%-16s %d, %d MB\n", "Attachments", stats.Attachments, stats.AttachmentsSize>>20)
+	fmt.Printf("%-16s %d\n", "Open reports", stats.OpenReports)
+	fmt.Printf("%-16s %d\n", "Active webhooks", stats.Webhooks)
+	return 0
+}
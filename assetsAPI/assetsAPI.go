@@ -0,0 +1,206 @@
+package assetsAPI
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	// hashLength is the number of hex characters of the content hash added to the names of the files
+	hashLength = 10
+	// immutable is the Cache-Control of the files requested by their hashed name, whose content never changes
+	immutable = "public, max-age=31536000, immutable"
+	// revalidate is the Cache-Control of the files requested by their name, whose content changes with the forum
+	revalidate = "no-cache"
+)
+
+// Assets serves the static files of a file system, the styles, scripts and images of the forum, under a URL prefix.
+// Loaded assets are read once and served from memory under names carrying a hash of their content, as
+// CSS/post.3f2a1b9c0d.css, cached for a year and precompressed with brotli and gzip. Live assets are read from the file
+// system at each request and revalidated by the browsers, for the development.
+type Assets struct {
+	prefix  string
+	exclude []string
+	live    http.Handler
+	files   map[string]*file
+	hashed  map[string]string
+}
+
+// file is a loaded file, with its compressed contents when they are smaller
+type file struct {
+	contentType string
+	hash        string
+	identity    []byte
+	gzip        []byte
+	brotli      []byte
+}
+
+// Load reads the files of fsys but the ones in the excluded directories, and compresses them
+func Load(fsys fs.FS, prefix string, exclude ...string) (*Assets, error) {
+	a := &Assets{prefix: prefix, exclude: exclude, files: map[string]*file{}, hashed: map[string]string{}}
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if a.excluded(name) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		f, err := newFile(name, content)
+		if err != nil {
+			return err
+		}
+		a.files[name] = f
+		a.hashed[name] = hashedName(name, f.hash)
+		a.files[a.hashed[name]] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Live serves the files of fsys but the ones in the excluded directories as they are on the file system
+func Live(fsys fs.FS, prefix string, exclude ...string) *Assets {
+	return &Assets{prefix: prefix, exclude: exclude, live: http.StripPrefix(prefix, http.FileServer(http.FS(fsys)))}
+}
+
+// newFile returns a loaded file
+func newFile(name string, content []byte) (*file, error) {
+	sum := sha256.Sum256(content)
+	f := &file{contentType: mime.TypeByExtension(path.Ext(name)), hash: hex.EncodeToString(sum[:])[:hashLength], identity: content}
+	if f.contentType == "" {
+		f.contentType = http.DetectContentType(content)
+	}
+	if !compressible(f.contentType) {
+		return f, nil
+	}
+	var compressed bytes.Buffer
+	gzipWriter, _ := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	if _, err := gzipWriter.Write(content); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	if compressed.Len() < len(content) {
+		f.gzip = bytes.Clone(compressed.Bytes())
+	}
+	compressed.Reset()
+	brotliWriter := brotli.NewWriterLevel(&compressed, brotli.BestCompression)
+	if _, err := brotliWriter.Write(content); err != nil {
+		return nil, err
+	}
+	if err := brotliWriter.Close(); err != nil {
+		return nil, err
+	}
+	if compressed.Len() < len(content) {
+		f.brotli = bytes.Clone(compressed.Bytes())
+	}
+	return f, nil
+}
+
+// compressible returns true for the content types worth compressing, the images and archives being compressed already
+func compressible(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(mediaType, "text/") || mediaType == "application/javascript" ||
+		mediaType == "application/json" || mediaType == "image/svg+xml"
+}
+
+// hashedName returns the name of a file with a hash of its content before its extension
+func hashedName(name string, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// excluded returns true if a file or directory is in an excluded directory
+func (a *Assets) excluded(name string) bool {
+	for _, dir := range a.exclude {
+		if name == dir || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Path returns the URL path of a file, under its hashed name when it is loaded
+func (a *Assets) Path(name string) string {
+	if hashed, ok := a.hashed[name]; ok {
+		return a.prefix + hashed
+	}
+	return a.prefix + name
+}
+
+// ServeHTTP serves a file, in the best encoding accepted by the client
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, a.prefix)
+	if a.excluded(name) {
+		http.NotFound(w, r)
+		return
+	}
+	if a.live != nil {
+		w.Header().Set("Cache-Control", revalidate)
+		a.live.ServeHTTP(w, r)
+		return
+	}
+	f, ok := a.files[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if _, original := a.hashed[name]; original {
+		// the pages link the hashed names, the original ones are still served for the links from elsewhere
+		w.Header().Set("Cache-Control", revalidate)
+	} else {
+		w.Header().Set("Cache-Control", immutable)
+	}
+	content, etag := f.identity, `"`+f.hash+`"`
+	if f.gzip != nil || f.brotli != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if f.brotli != nil && acceptsEncoding(r, "br") {
+		w.Header().Set("Content-Encoding", "br")
+		content, etag = f.brotli, `"`+f.hash+`-br"`
+	} else if f.gzip != nil && acceptsEncoding(r, "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		content, etag = f.gzip, `"`+f.hash+`-gz"`
+	}
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
+
+// acceptsEncoding returns true if the Accept-Encoding header of a request accepts an encoding, without a zero quality
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(accepted), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		quality := strings.ReplaceAll(params, " ", "")
+		return quality != "q=0" && quality != "q=0.0" && quality != "q=0.00" && quality != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"FORUM-GO/backupAPI"
+	"FORUM-GO/configAPI"
+	"FORUM-GO/databaseAPI"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// runBackup runs the backup subcommand, which backs the database up while the server may be running, and returns the
+// exit code
+func runBackup(args []string) int {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	configFlags := configAPI.AddFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: forum backup [flags] [file]")
+		fmt.Fprintln(flags.Output(), "Without file, the backup is written in the backups directory and the oldest backups are deleted.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+	if !loadConfig(configFlags) {
+		return 2
+	}
+	if config.Database.Driver == "postgres" {
+		fmt.Println("Unable to back up: " + databaseAPI.ErrPostgresBackup.Error())
+		return 1
+	}
+	// the database is opened read-only and copied as it is, without creating its tables or migrating it
+	database, err := backupAPI.Open(config.Database.Path)
+	if err != nil {
+		fmt.Println("Unable to back up " + config.Database.Path + ": " + err.Error())
+		return 1
+	}
+	defer database.Close()
+	path := flags.Arg(0)
+	if path == "" {
+		var name string
+		name, err = backupAPI.Create(database, config.Backups.Dir, config.Backups.Keep)
+		path = filepath.Join(config.Backups.Dir, name)
+	} else {
+		err = backupAPI.Write(database, path)
+	}
+	if err != nil {
+		fmt.Println("Backup failed: " + err.Error())
+		return 1
+	}
+	fmt.Println("Database backed up to " + path + " at " + time.Now().Format("2006-01-02 15:04:05"))
+	return 0
+}
+
+// runRestore runs the restore subcommand, which checks a backup and swaps it in place of the database while the
+// server is stopped, and returns the exit code
+func runRestore(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	check := flags.Bool("check", false, "only check the backup")
+	configFlags := configAPI.AddFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: forum restore [flags] file")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if !loadConfig(configFlags) {
+		return 2
+	}
+	if config.Database.Driver == "postgres" {
+		fmt.Println("Unable to restore: " + databaseAPI.ErrPostgresBackup.Error())
+		return 1
+	}
+	backup := flags.Arg(0)
+	if *check {
+		if err := backupAPI.Check(backup); err != nil {
+			fmt.Println("Backup " + backup + " can't be restored: " + err.Error())
+			return 1
+		}
+		fmt.Println("Backup " + backup + " can be restored")
+		return 0
+	}
+	previous, err := backupAPI.Restore(backup, config.Database.Path)
+	if err != nil {
+		fmt.Println("Restore of " + backup + " failed: " + err.Error())
+		return 1
+	}
+	if previous != "" {
+		fmt.Println("The previous database has been moved to " + previous)
+	}
+	fmt.Println("Restored " + backup + " at " + time.Now().Format("2006-01-02 15:04:05"))
+	return 0
+}
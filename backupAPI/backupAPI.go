@@ -0,0 +1,231 @@
+package backupAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	backupPrefix = "database-"
+	backupSuffix = ".db"
+	// backupTimeFormat sorts the backups of a directory by date when they are sorted by name
+	backupTimeFormat = "2006-01-02-150405"
+)
+
+// Backup is a backup file of a backups directory
+type Backup struct {
+	Name      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// Write writes a consistent copy of a database in use to a file, through a temporary file so that the file is never
+// partially written
+func Write(database *sql.DB, path string) error {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	temporary := filepath.Join(dir, filepath.Base(path))
+	if err := databaseAPI.VacuumInto(database, temporary); err != nil {
+		return err
+	}
+	return os.Rename(temporary, path)
+}
+
+// WriteWithoutSessions writes a copy of a backup without the session tokens of the users to a file, so that the copy
+// can't be used to log in as them
+func WriteWithoutSessions(backup string, path string) error {
+	source, err := Open(backup)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	if err := databaseAPI.VacuumInto(source, path); err != nil {
+		return err
+	}
+	database, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	return databaseAPI.ClearSessions(database)
+}
+
+// Create writes a backup of a database in a directory, named after the current time, and deletes the oldest backups
+// of the directory to keep only the given number of them
+func Create(database *sql.DB, dir string, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := backupPrefix + time.Now().Format(backupTimeFormat) + backupSuffix
+	if err := Write(database, filepath.Join(dir, name)); err != nil {
+		return "", err
+	}
+	return name, rotate(dir, keep)
+}
+
+// List returns the backups of a directory, the latest first
+func List(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []Backup
+	for _, entry := range entries {
+		if entry.IsDir() || !IsBackupName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// IsBackupName returns true if a file name is the name of a backup made by Create
+func IsBackupName(name string) bool {
+	if !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return false
+	}
+	_, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix))
+	return err == nil
+}
+
+// rotate deletes the oldest backups of a directory to keep only the given number of them, 0 keeping all of them
+func rotate(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	backups, err := List(dir)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(dir, backups[i].Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open opens a SQLite database read-only, to copy or check it without changing it
+func Open(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return sql.Open(databaseAPI.ObservedDriver, "file:"+(&url.URL{Path: path}).EscapedPath()+"?mode=ro")
+}
+
+// Check opens a backup read-only and returns an error if it can't be restored: if it is corrupted, isn't a database
+// of the forum or has a more recent schema
+func Check(path string) error {
+	database, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	return databaseAPI.CheckDatabase(database)
+}
+
+// lock takes an exclusive lock on a database, which fails while another connection reads or writes it, and returns
+// the function releasing it. A database that doesn't exist isn't locked.
+func lock(path string) (func(), error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return func() {}, nil
+	}
+	database, err := sql.Open("sqlite3", "file:"+(&url.URL{Path: path}).EscapedPath()+"?_busy_timeout=1000")
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	conn, err := database.Conn(ctx)
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "BEGIN EXCLUSIVE")
+		if err != nil {
+			conn.Close()
+		}
+	}
+	if err != nil {
+		database.Close()
+		return nil, errors.New(path + " can't be locked, the database is in use: " + err.Error())
+	}
+	return func() {
+		conn.ExecContext(ctx, "ROLLBACK")
+		conn.Close()
+		database.Close()
+	}, nil
+}
+
+// Restore checks a backup and swaps it in place of the database, which must not be in use: the database is locked
+// during the swap, and the restore fails if it can't be. The current database is kept next to it, its path is
+// returned.
+func Restore(backup string, databasePath string) (string, error) {
+	for _, suffix := range []string{"-journal", "-wal"} {
+		if _, err := os.Stat(databasePath + suffix); err == nil {
+			return "", errors.New(databasePath + suffix + " exists, the database is in use or wasn't closed properly")
+		}
+	}
+	unlock, err := lock(databasePath)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if err := Check(backup); err != nil {
+		return "", err
+	}
+	// the backup is copied next to the database, so that the swap is a rename
+	source, err := os.Open(backup)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	copied, err := os.CreateTemp(filepath.Dir(databasePath), ".restore-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(copied.Name())
+	if _, err := io.Copy(copied, source); err != nil {
+		copied.Close()
+		return "", err
+	}
+	if err := copied.Sync(); err != nil {
+		copied.Close()
+		return "", err
+	}
+	if err := copied.Close(); err != nil {
+		return "", err
+	}
+	// the copy is checked again, in case the backup changed while being copied
+	if err := Check(copied.Name()); err != nil {
+		return "", err
+	}
+	previous := databasePath + ".before-restore-" + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(databasePath, previous); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		previous = ""
+	}
+	return previous, os.Rename(copied.Name(), databasePath)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"FORUM-GO/configAPI"
+	"FORUM-GO/databaseAPI"
+	"FORUM-GO/logAPI"
+	"FORUM-GO/webAPI"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// config is the configuration of the forum, loaded by the subcommands
+var config configAPI.Config
+
+// logger logs the events and the errors of the forum as configured
+var logger = slog.Default()
+
+// loadConfig loads the configuration from the parsed flags of a subcommand, printing why it is invalid
+func loadConfig(flags *configAPI.Flags) bool {
+	var err error
+	if config, err = flags.Load(); err != nil {
+		fmt.Println("Invalid configuration: " + err.Error())
+		return false
+	}
+	if logger, err = logAPI.New(os.Stdout, config.Log.Format, config.Log.Level); err != nil {
+		fmt.Println("Invalid configuration: " + err.Error())
+		return false
+	}
+	slog.SetDefault(logger)
+	webAPI.SetLogger(logger)
+	databaseAPI.SetBcryptCost(config.Sessions.BcryptCost)
+	return true
+}
+
+// runConfig runs the config subcommand, which prints the configuration given by the configuration file, the
+// environment and the flags as a documented configuration file, and returns the exit code
+func runConfig(args []string) int {
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	configFlags := configAPI.AddFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: forum config print [flags]")
+		fmt.Fprintln(flags.Output(), "Prints the configuration, read from "+configAPI.DefaultFile+", the FORUM_ environment variables and the flags.")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 || args[0] != "print" {
+		flags.Usage()
+		return 2
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+	if !loadConfig(configFlags) {
+		return 1
+	}
+	if err := config.Print(os.Stdout); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,165 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"strings"
+)
+
+// DeletedUsername replaces the username of the posts and comments of anonymized accounts
+const DeletedUsername = "[deleted]"
+
+// upvotesCount and downvotesCount count the votes of a post of the posts table in the votes table
+const (
+	upvotesCount   = "(SELECT COUNT(*) FROM votes WHERE votes.post_id = posts.id AND votes.vote = 1)"
+	downvotesCount = "(SELECT COUNT(*) FROM votes WHERE votes.post_id = posts.id AND votes.vote = -1)"
+)
+
+// recountVotesQuery sets the votes counts of all the posts from the votes table
+const recountVotesQuery = "UPDATE posts SET upvotes = " + upvotesCount + ", downvotes = " + downvotesCount
+
+// GetUserComments returns all the comments of a user
+func GetUserComments(database *sql.DB, username string) ([]Comment, error) {
+	rows, err := database.Query("SELECT id, post_id, parent_id, username, content, created_at FROM comments WHERE username = ? ORDER BY id", username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var comments []Comment
+	for rows.Next() {
+		var comment Comment
+		if err := rows.Scan(&comment.Id, &comment.PostId, &comment.ParentId, &comment.Username, &comment.Content, scanTime(&comment.CreatedAt)); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// GetUserVotes returns all the votes of a user
+func GetUserVotes(database *sql.DB, username string) ([]Vote, error) {
+	rows, err := database.Query("SELECT v.post_id, COALESCE(p.title, ''), v.vote FROM votes v LEFT JOIN posts p ON p.id = v.post_id WHERE v.username = ? ORDER BY v.id", username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var votes []Vote
+	for rows.Next() {
+		var vote Vote
+		if err := rows.Scan(&vote.PostId, &vote.PostTitle, &vote.Vote); err != nil {
+			return nil, err
+		}
+		votes = append(votes, vote)
+	}
+	return votes, rows.Err()
+}
+
+// DeleteUser deletes an account, its votes and the webhook deliveries of its actions, and recounts the votes of the
+// posts. With purge, the posts and the comments of the user are deleted with the webhook deliveries about them,
+// otherwise they are kept with DeletedUsername as author. It returns the keys of the blobs of the deleted attachments
+// that no other attachment uses.
+func DeleteUser(database *sql.DB, username string, purge bool) ([]string, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	const posts = "SELECT id FROM posts WHERE username = ?"
+	const comments = "SELECT id FROM comments WHERE username = ?"
+	var blobKeys []string
+	if purge {
+		if blobKeys, err = attachmentBlobs(tx, posts, username); err != nil {
+			return nil, err
+		}
+	}
+	// the payloads of the deliveries hold the username and the content of the posts and comments
+	deliveries := "DELETE FROM webhook_deliveries WHERE username = ?"
+	if purge {
+		deliveries += " OR post_id IN (" + posts + ")"
+	}
+	statements := []string{
+		deliveries,
+		"DELETE FROM votes WHERE username = ?",
+		"DELETE FROM notifications WHERE username = ? OR actor = ?",
+		"DELETE FROM subscriptions WHERE username = ? OR (kind = 'user' AND target = ?)",
+		"DELETE FROM bookmarks WHERE username = ?",
+		"DELETE FROM email_preferences WHERE username = ?",
+		"DELETE FROM user_settings WHERE username = ?",
+	}
+	if purge {
+		statements = append(statements,
+			"DELETE FROM attachments WHERE post_id IN ("+posts+")",
+			"DELETE FROM votes WHERE post_id IN ("+posts+")",
+			"DELETE FROM bookmarks WHERE post_id IN ("+posts+")",
+			"DELETE FROM notifications WHERE post_id IN ("+posts+") OR comment_id IN ("+comments+")",
+			"DELETE FROM reports WHERE post_id IN ("+posts+") OR comment_id IN ("+comments+")",
+			"DELETE FROM subscriptions WHERE kind = 'thread' AND target IN (SELECT CAST(id AS TEXT) FROM posts WHERE username = ?)",
+			// the replies to the deleted comments stay, as comments to the post
+			"UPDATE comments SET parent_id = 0 WHERE parent_id IN ("+comments+")",
+			"DELETE FROM comments WHERE post_id IN ("+posts+") OR username = ?",
+			"DELETE FROM posts WHERE username = ?",
+		)
+	} else {
+		statements = append(statements,
+			"UPDATE posts SET username = '"+DeletedUsername+"' WHERE username = ?",
+			"UPDATE comments SET username = '"+DeletedUsername+"' WHERE username = ?",
+			"UPDATE attachments SET username = '"+DeletedUsername+"' WHERE username = ?",
+		)
+	}
+	statements = append(statements,
+		"UPDATE reports SET username = '"+DeletedUsername+"' WHERE username = ?",
+		"DELETE FROM users WHERE username = ?",
+	)
+	for _, statement := range statements {
+		args := make([]interface{}, strings.Count(statement, "?"))
+		for i := range args {
+			args[i] = username
+		}
+		if _, err := tx.Exec(statement, args...); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(recountVotesQuery); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return UnusedBlobs(database, blobKeys)
+}
+
+// attachmentBlobs returns the keys of the blobs and thumbnails of the attachments of the posts selected by a query
+func attachmentBlobs(tx *sql.Tx, posts string, args ...interface{}) ([]string, error) {
+	rows, err := tx.Query("SELECT blob_key, thumbnail_key FROM attachments WHERE post_id IN ("+posts+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var blobKeys []string
+	for rows.Next() {
+		var blobKey, thumbnailKey string
+		if err := rows.Scan(&blobKey, &thumbnailKey); err != nil {
+			return nil, err
+		}
+		blobKeys = append(blobKeys, blobKey)
+		if thumbnailKey != "" {
+			blobKeys = append(blobKeys, thumbnailKey)
+		}
+	}
+	return blobKeys, rows.Err()
+}
+
+// UnusedBlobs returns the keys of blobs that no attachment uses anymore, once their attachments are deleted
+func UnusedBlobs(database *sql.DB, blobKeys []string) ([]string, error) {
+	var unused []string
+	for _, key := range blobKeys {
+		var count int
+		if err := database.QueryRow("SELECT COUNT(*) FROM attachments WHERE blob_key = ? OR thumbnail_key = ?", key, key).Scan(&count); err != nil {
+			return unused, err
+		}
+		if count == 0 && !containsString(unused, key) {
+			unused = append(unused, key)
+		}
+	}
+	return unused, nil
+}
@@ -0,0 +1,215 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	"errors"
+	_ "github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
+)
+
+// The roles of the users
+const (
+	RoleUser   = "user"
+	RoleAdmin  = "admin"
+	RoleBanned = "banned"
+)
+
+// SetRole sets the role of a user, ErrNotFound if the user doesn't exist
+func SetRole(database *sql.DB, username string, role string) error {
+	return execError(database.Exec("UPDATE users SET role = ? WHERE username = ?", role, username))
+}
+
+// SetAdmin makes a user an admin, or a user again if admin is false. It returns ErrBanned if the user is banned, their
+// ban must be lifted first, and ErrNotFound if the user doesn't exist.
+func SetAdmin(database *sql.DB, username string, admin bool) error {
+	role := RoleUser
+	if admin {
+		role = RoleAdmin
+	}
+	err := execError(database.Exec("UPDATE users SET role = ? WHERE username = ? AND role != ?", role, username, RoleBanned))
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	banned, err := IsBanned(database, username)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return ErrBanned
+	}
+	return ErrNotFound
+}
+
+// IsBanned returns true if the user is banned from the forum
+func IsBanned(database *sql.DB, username string) (bool, error) {
+	var role string
+	err := database.QueryRow("SELECT role FROM users WHERE username = ?", username).Scan(&role)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return role == RoleBanned, err
+}
+
+// SetPassword sets the password of a user and logs them out, ErrNotFound if the user doesn't exist
+func SetPassword(database *sql.DB, username string, password string) error {
+	password, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return execError(database.Exec("UPDATE users SET password = ?, cookie = '', expires = '' WHERE username = ?", password, username))
+}
+
+// AddCategory adds a category, with the default icon if icon is empty. It returns ErrConflict if the category exists.
+func AddCategory(database *sql.DB, name string, icon string) error {
+	if icon == "" {
+		icon = defaultCategoryIcon
+	}
+	result, err := database.Exec("INSERT INTO categories (name, icon) SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM categories WHERE name = ?)", name, icon, name)
+	if err := execError(result, err); err != ErrNotFound {
+		return err
+	}
+	return ErrConflict
+}
+
+// inCategory selects the posts of a category among the comma-separated categories of the posts table
+var inCategory = categoryIn("categories", "?")
+
+// categoryIn returns the condition of a category, an SQL expression, being in comma-separated categories. The
+// wildcards of LIKE are escaped in the category, so that only a category of the same name matches.
+func categoryIn(categories string, category string) string {
+	escaped := "REPLACE(REPLACE(REPLACE(" + category + ", '\\', '\\\\'), '%', '\\%'), '_', '\\_')"
+	return "',' || " + categories + " || ',' LIKE '%,' || " + escaped + " || ',%' ESCAPE '\\'"
+}
+
+// CountPostsInCategory returns the number of posts in a category
+func CountPostsInCategory(database *sql.DB, name string) (int, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM posts WHERE "+inCategory, name).Scan(&count)
+	return count, err
+}
+
+// RemoveCategory removes a category, ErrNotFound if it doesn't exist. Its posts and followers are moved to the
+// category moveTo, which must exist, or with an empty moveTo it must have no posts and its followers are removed,
+// otherwise it returns ErrConstraint.
+func RemoveCategory(database *sql.DB, name string, moveTo string) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := execError(tx.Exec("DELETE FROM categories WHERE name = ?", name)); err != nil {
+		return err
+	}
+	if moveTo == "" {
+		var count int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM posts WHERE "+inCategory, name).Scan(&count); err != nil {
+			return err
+		}
+		if count != 0 {
+			return ErrConstraint
+		}
+	} else {
+		var count int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM categories WHERE name = ?", moveTo).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrConstraint
+		}
+		// the posts already in moveTo only lose the removed category
+		statements := [][]interface{}{
+			{"UPDATE posts SET categories = TRIM(REPLACE(',' || categories || ',', ',' || ? || ',', ','), ',') WHERE " + inCategory + " AND " + inCategory, name, name, moveTo},
+			{"UPDATE posts SET categories = TRIM(REPLACE(',' || categories || ',', ',' || ? || ',', ',' || ? || ','), ',') WHERE " + inCategory, name, moveTo, name},
+			// the users already following moveTo keep their subscription to it
+			{"DELETE FROM subscriptions WHERE kind = 'category' AND target = ? AND username IN (SELECT username FROM subscriptions WHERE kind = 'category' AND target = ?)", name, moveTo},
+			{"UPDATE subscriptions SET target = ? WHERE kind = 'category' AND target = ?", moveTo, name},
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement[0].(string), statement[1:]...); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM subscriptions WHERE kind = 'category' AND target = ?", name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeletePost deletes a post with its comments, votes, attachments and everything referencing them, ErrNotFound if it
+// doesn't exist. It returns the keys of the blobs of the deleted attachments that no other attachment uses.
+func DeletePost(database *sql.DB, id int) ([]string, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	blobKeys, err := attachmentBlobs(tx, "?", id)
+	if err != nil {
+		return nil, err
+	}
+	const comments = "SELECT id FROM comments WHERE post_id = ?"
+	statements := []string{
+		"DELETE FROM attachments WHERE post_id = ?",
+		"DELETE FROM votes WHERE post_id = ?",
+		"DELETE FROM bookmarks WHERE post_id = ?",
+		"DELETE FROM notifications WHERE post_id = ? OR comment_id IN (" + comments + ")",
+		"DELETE FROM reports WHERE post_id = ? OR comment_id IN (" + comments + ")",
+		"DELETE FROM subscriptions WHERE kind = 'thread' AND target = CAST(? AS TEXT)",
+		"DELETE FROM comments WHERE post_id = ?",
+	}
+	for _, statement := range statements {
+		args := make([]interface{}, strings.Count(statement, "?"))
+		for i := range args {
+			args[i] = id
+		}
+		if _, err := tx.Exec(statement, args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := execError(tx.Exec("DELETE FROM posts WHERE id = ?", id)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return UnusedBlobs(database, blobKeys)
+}
+
+// RecountVotes sets the votes counts of the posts from the votes table, and returns the number of posts whose counts
+// were wrong
+func RecountVotes(database *sql.DB) (int, error) {
+	// the counts are never negative, the NULL counts of the posts of older versions are wrong too
+	result, err := database.Exec(recountVotesQuery + " WHERE COALESCE(upvotes, -1) != " + upvotesCount + " OR COALESCE(downvotes, -1) != " + downvotesCount)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+// GetStats returns the numbers of rows of the forum, with the sessions active at now
+func GetStats(database *sql.DB, now time.Time) (Stats, error) {
+	var stats Stats
+	err := database.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM users),
+		(SELECT COUNT(*) FROM users WHERE role = '`+RoleAdmin+`'),
+		(SELECT COUNT(*) FROM users WHERE role = '`+RoleBanned+`'),
+		(SELECT COUNT(*) FROM users WHERE cookie != '' AND expires > ?),
+		(SELECT COUNT(*) FROM categories),
+		(SELECT COUNT(*) FROM posts),
+		(SELECT COUNT(*) FROM comments),
+		(SELECT COUNT(*) FROM votes),
+		(SELECT COUNT(*) FROM attachments),
+		(SELECT COALESCE(SUM(size), 0) FROM attachments),
+		(SELECT COUNT(*) FROM reports WHERE status = 'open'),
+		(SELECT COUNT(*) FROM webhooks WHERE active = 1)`, FormatTime(now)).Scan(
+		&stats.Users, &stats.Admins, &stats.Banned, &stats.ActiveSessions, &stats.Categories, &stats.Posts,
+		&stats.Comments, &stats.Votes, &stats.Attachments, &stats.AttachmentsSize, &stats.OpenReports, &stats.Webhooks)
+	if err != nil {
+		return stats, err
+	}
+	stats.SchemaVersion, err = GetSchemaVersion(database)
+	return stats, err
+}
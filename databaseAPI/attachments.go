@@ -0,0 +1,49 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"time"
+)
+
+// addAttachment adds a file attachment to a post
+func addAttachment(tx *sql.Tx, attachment Attachment, createdAt time.Time) error {
+	createdAtString := FormatTime(createdAt)
+	_, err := tx.Exec("INSERT INTO attachments (post_id, username, filename, mime_type, size, blob_key, thumbnail_key, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		attachment.PostId, attachment.Username, attachment.Filename, attachment.MimeType, attachment.Size, attachment.BlobKey, attachment.ThumbnailKey, createdAtString)
+	return err
+}
+
+// GetAttachments returns the attachments of a post
+func GetAttachments(database *sql.DB, postId string) ([]Attachment, error) {
+	id, ok := parseId(postId)
+	if !ok {
+		return nil, nil
+	}
+	rows, err := database.Query("SELECT id, post_id, username, filename, mime_type, size, blob_key, thumbnail_key, created_at FROM attachments WHERE post_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var attachments []Attachment
+	for rows.Next() {
+		var attachment Attachment
+		if err := rows.Scan(&attachment.Id, &attachment.PostId, &attachment.Username, &attachment.Filename, &attachment.MimeType, &attachment.Size, &attachment.BlobKey, &attachment.ThumbnailKey, &attachment.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// GetAttachment returns an attachment by id, ErrNotFound if it doesn't exist or if its post has been deleted
+func GetAttachment(database *sql.DB, id string) (Attachment, error) {
+	var attachment Attachment
+	attachmentId, ok := parseId(id)
+	if !ok {
+		return attachment, ErrNotFound
+	}
+	err := database.QueryRow("SELECT a.id, a.post_id, a.username, a.filename, a.mime_type, a.size, a.blob_key, a.thumbnail_key, a.created_at FROM attachments a JOIN posts p ON p.id = a.post_id WHERE a.id = ?", attachmentId).
+		Scan(&attachment.Id, &attachment.PostId, &attachment.Username, &attachment.Filename, &attachment.MimeType, &attachment.Size, &attachment.BlobKey, &attachment.ThumbnailKey, &attachment.CreatedAt)
+	return attachment, dbError(err)
+}
@@ -1,82 +1,97 @@
 package databaseAPI
 
 import (
+	"FORUM-GO/metricsAPI"
 	"database/sql"
-	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
 	"time"
 )
 
-// AddUser adds a user to the database
-func AddUser(database *sql.DB, username string, email string, password string, cookie string, expires string) {
-	password, _ = hashPassword(password)
-	statement, _ := database.Prepare("INSERT INTO users (username, email, password, cookie, expires) VALUES (?, ?, ?, ?, ?)")
-	statement.Exec(username, email, password, cookie, expires)
-	now := time.Now().Format("2006-01-02 15:04:05")
-	fmt.Println("Added user: " + username + " with email: " + email + " at " + now)
+// AddUser adds a user to the database, who isn't an admin. It returns ErrConflict if the username
+// or the email is taken.
+func AddUser(database *sql.DB, username string, email string, password string, cookie string, expires string) error {
+	password, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	result, err := database.Exec(`INSERT INTO users (username, email, password, cookie, expires, role)
+		SELECT ?, ?, ?, ?, ?, 'user'
+		WHERE NOT EXISTS (SELECT 1 FROM users WHERE username = ? OR email = ?)`, username, email, password, cookie, expires, username, email)
+	// the statement adds no user when the username or the email is taken
+	if err := execError(result, err); err != ErrNotFound {
+		return err
+	}
+	return ErrConflict
 }
 
 // EmailNotTaken returns true if the email is not taken
-func EmailNotTaken(database *sql.DB, email string) bool {
-	rows, _ := database.Query("SELECT email FROM users WHERE email = ?", email)
-	var emailExists string
-	for rows.Next() {
-		rows.Scan(&emailExists)
-	}
-	if emailExists == "" {
-		return true
-	}
-	return false
+func EmailNotTaken(database *sql.DB, email string) (bool, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM users WHERE email = ?", email).Scan(&count)
+	return count == 0, err
 }
 
 // UsernameNotTaken returns true if the username is not taken
-func UsernameNotTaken(database *sql.DB, username string) bool {
-	rows, _ := database.Query("SELECT username FROM users WHERE username = ?", username)
-	var usernameExists string
-	for rows.Next() {
-		rows.Scan(&usernameExists)
-	}
-	if usernameExists == "" {
-		return true
-	}
-	return false
+func UsernameNotTaken(database *sql.DB, username string) (bool, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", username).Scan(&count)
+	return count == 0, err
 }
 
 // CheckCookie checks if a cookie is valid
-func CheckCookie(database *sql.DB, cookie string) bool {
-	var result bool
-	err := database.QueryRow("SELECT IIF(COUNT(*), 'true', 'false') FROM users WHERE cookie = ?", cookie).Scan(&result)
-	if err != nil {
-		return false
-	}
-	return result
+func CheckCookie(database *sql.DB, cookie string) (bool, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM users WHERE cookie = ?", cookie).Scan(&count)
+	return count != 0, err
 }
 
-// GetExpires returns the expiration date of a cookie
-func GetExpires(database *sql.DB, cookie string) string {
+// GetExpires returns the expiration date of a cookie, ErrNotFound if no user has it
+func GetExpires(database *sql.DB, cookie string) (string, error) {
 	var expires string
-	rows, _ := database.Query("SELECT expires FROM users WHERE cookie = ?", cookie)
-	for rows.Next() {
-		rows.Scan(&expires)
-	}
-	return expires
+	err := database.QueryRow("SELECT expires FROM users WHERE cookie = ?", cookie).Scan(&expires)
+	return expires, dbError(err)
 }
 
 // Logout logs a user out
-func Logout(database *sql.DB, username string) {
-	statement, _ := database.Prepare("UPDATE users SET cookie = '', expires = '' WHERE username = ?")
-	statement.Exec(username)
+func Logout(database *sql.DB, username string) error {
+	return execError(database.Exec("UPDATE users SET cookie = '', expires = '' WHERE username = ?", username))
 }
 
-// UpdateCookie updates the cookie of a user
-func UpdateCookie(database *sql.DB, token string, expiration time.Time, email string) {
-	statement, _ := database.Prepare("UPDATE users SET cookie = ?, expires = ? WHERE email = ?")
-	statement.Exec(token, expiration.Format("2006-01-02 15:04:05"), email)
+// UpdateCookie updates the cookie of a user, ErrNotFound if no user has the email
+func UpdateCookie(database *sql.DB, token string, expiration time.Time, email string) error {
+	return execError(database.Exec("UPDATE users SET cookie = ?, expires = ? WHERE email = ?", token, FormatTime(expiration), email))
+}
+
+// bcryptCost is the cost of the bcrypt hashes of the passwords
+var bcryptCost = 14
+
+// SetBcryptCost sets the cost of the bcrypt hashes of the passwords
+func SetBcryptCost(cost int) {
+	bcryptCost = cost
 }
 
 // hashPassword hashes the password
 func hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	defer observeBcrypt("hash", time.Now())
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	return string(bytes), err
 }
+
+// observeBcrypt observes the duration of a bcrypt operation since start
+func observeBcrypt(operation string, start time.Time) {
+	metricsAPI.BcryptDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// CompareHashAndPassword returns nil if the password matches the bcrypt hash
+func CompareHashAndPassword(hash string, password string) error {
+	defer observeBcrypt("compare", time.Now())
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+// CountActiveSessions returns the number of sessions that haven't expired at now
+func CountActiveSessions(database *sql.DB, now time.Time) (int, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM users WHERE cookie != '' AND expires > ?", FormatTime(now)).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,140 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// SchemaVersion is the version of the schema of the database, saved in its user_version with SQLite and in the
+// schema_version table with PostgreSQL. It is increased whenever a change of the schema can't be applied by the Create
+// functions to an older database, Migrate migrates the rows of the older versions. The version 2 saves the times in UTC.
+const SchemaVersion = 2
+
+// SetSchemaVersion saves the schema version of the forum in the database, once its tables are created
+func SetSchemaVersion(database *sql.DB) error {
+	if !isPostgres(database) {
+		_, err := database.Exec("PRAGMA user_version = " + strconv.Itoa(SchemaVersion))
+		return err
+	}
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS schema_version (version INTEGER)",
+		"DELETE FROM schema_version",
+		"INSERT INTO schema_version (version) VALUES (" + strconv.Itoa(SchemaVersion) + ")",
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSchemaVersion returns the schema version saved in a database, 0 for a database created before versions
+func GetSchemaVersion(database *sql.DB) (int, error) {
+	var version int
+	if !isPostgres(database) {
+		err := database.QueryRow("PRAGMA user_version").Scan(&version)
+		return version, err
+	}
+	var created bool
+	if err := database.QueryRow("SELECT to_regclass('schema_version') IS NOT NULL").Scan(&created); err != nil || !created {
+		return 0, err
+	}
+	err := database.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// ErrPostgresBackup is returned by the backups of a PostgreSQL database, which are made with pg_dump
+var ErrPostgresBackup = errors.New("the backups of a PostgreSQL database are made with pg_dump")
+
+// VacuumInto writes a consistent copy of the database to a new file, while it is still in use. It returns
+// ErrPostgresBackup for a PostgreSQL database.
+func VacuumInto(database *sql.DB, path string) error {
+	if isPostgres(database) {
+		return ErrPostgresBackup
+	}
+	_, err := database.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// ClearSessions logs all the users out, overwriting their session tokens in the file of the database
+func ClearSessions(database *sql.DB) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// without secure_delete, the previous values can stay in the free space of the pages
+	if _, err := tx.Exec("PRAGMA secure_delete = ON"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE users SET cookie = '', expires = ''"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CheckDatabase returns an error if a database is corrupted, isn't a database of the forum or has a schema more
+// recent than this version of the forum
+func CheckDatabase(database *sql.DB) error {
+	var result string
+	if err := database.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return errors.New("integrity check failed: " + result)
+	}
+	var tables int
+	if err := database.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('users', 'posts', 'comments', 'votes')").Scan(&tables); err != nil {
+		return err
+	}
+	if tables != 4 {
+		return errors.New("not a database of the forum")
+	}
+	return CheckSchemaVersion(database)
+}
+
+// CheckSchemaVersion returns an error if the schema of a database is more recent than this version of the forum
+func CheckSchemaVersion(database *sql.DB) error {
+	version, err := GetSchemaVersion(database)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return errors.New("schema version " + strconv.Itoa(version) + " is more recent than the version " + strconv.Itoa(SchemaVersion) + " of this forum")
+	}
+	return nil
+}
+
+// CheckSchemaUpToDate returns an error if the schema of a database isn't the one of this version of the forum, an
+// older database must be migrated first
+func CheckSchemaUpToDate(database *sql.DB) error {
+	if err := CheckSchemaVersion(database); err != nil {
+		return err
+	}
+	version, err := GetSchemaVersion(database)
+	if err != nil {
+		return err
+	}
+	if version < SchemaVersion {
+		return errors.New("schema version " + strconv.Itoa(version) + " is older than the version " + strconv.Itoa(SchemaVersion) + " of this forum, run forum migrate first")
+	}
+	return nil
+}
+
+// IsEmpty returns true if a database has no table of the forum yet
+func IsEmpty(database *sql.DB) (bool, error) {
+	query := "SELECT COUNT(*) = 0 FROM sqlite_master WHERE type = 'table' AND name = 'users'"
+	if isPostgres(database) {
+		query = "SELECT to_regclass('users') IS NULL"
+	}
+	var empty bool
+	err := database.QueryRow(query).Scan(&empty)
+	return empty, err
+}
@@ -0,0 +1,84 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
+)
+
+// SaveBookmark bookmarks a post for a user, or moves the bookmark to another folder if the post is already saved
+func SaveBookmark(database *sql.DB, username string, postId int, folder string, createdAt time.Time) error {
+	createdAtString := FormatTime(createdAt)
+	_, err := database.Exec("INSERT INTO bookmarks (username, post_id, folder, created_at) VALUES (?, ?, ?, ?) ON CONFLICT (username, post_id) DO UPDATE SET folder = excluded.folder", username, postId, folder, createdAtString)
+	return err
+}
+
+// DeleteBookmark removes a post from the bookmarks of a user
+func DeleteBookmark(database *sql.DB, username string, postId int) error {
+	_, err := database.Exec("DELETE FROM bookmarks WHERE username = ? AND post_id = ?", username, postId)
+	return err
+}
+
+// GetBookmark returns the bookmark of a user on a post, ErrNotFound if the post isn't saved
+func GetBookmark(database *sql.DB, username string, postId int) (Bookmark, error) {
+	var bookmark Bookmark
+	err := database.QueryRow("SELECT b.id, b.post_id, p.title, b.folder, b.created_at FROM bookmarks b JOIN posts p ON p.id = b.post_id WHERE b.username = ? AND b.post_id = ?", username, postId).Scan(&bookmark.Id, &bookmark.PostId, &bookmark.PostTitle, &bookmark.Folder, &bookmark.CreatedAt)
+	return bookmark, dbError(err)
+}
+
+// GetBookmarks returns the bookmarks of a user, latest first, only the ones in a folder if it isn't empty
+func GetBookmarks(database *sql.DB, username string, folder string) ([]Bookmark, error) {
+	rows, err := database.Query("SELECT b.id, b.post_id, p.title, b.folder, b.created_at FROM bookmarks b JOIN posts p ON p.id = b.post_id WHERE b.username = ? AND (? = '' OR b.folder = ?) ORDER BY b.created_at DESC, b.id DESC", username, folder, folder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var bookmarks []Bookmark
+	for rows.Next() {
+		var bookmark Bookmark
+		if err := rows.Scan(&bookmark.Id, &bookmark.PostId, &bookmark.PostTitle, &bookmark.Folder, &bookmark.CreatedAt); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, rows.Err()
+}
+
+// GetSavedPosts returns the posts bookmarked by a user, latest bookmark first, only the ones in a folder if it isn't empty
+func GetSavedPosts(database *sql.DB, username string, folder string) ([]Post, error) {
+	rows, err := database.Query("SELECT p.id, p.username, p.title, p.categories, p.content, p.created_at, p.upvotes, p.downvotes FROM bookmarks b JOIN posts p ON p.id = b.post_id WHERE b.username = ? AND (? = '' OR b.folder = ?) ORDER BY b.created_at DESC, b.id DESC", username, folder, folder)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var posts []Post
+	for rows.Next() {
+		var post Post
+		var catString string
+		if err := rows.Scan(&post.Id, &post.Username, &post.Title, &catString, &post.Content, scanTime(&post.CreatedAt), &post.UpVotes, &post.DownVotes); err != nil {
+			return nil, err
+		}
+		post.Categories = strings.Split(catString, ",")
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// GetBookmarkFolders returns the folders used by a user for their bookmarks
+func GetBookmarkFolders(database *sql.DB, username string) ([]string, error) {
+	rows, err := database.Query("SELECT DISTINCT folder FROM bookmarks WHERE username = ? AND folder != '' ORDER BY folder", username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var folders []string
+	for rows.Next() {
+		var folder string
+		if err := rows.Scan(&folder); err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+	return folders, rows.Err()
+}
@@ -2,24 +2,148 @@ package databaseAPI
 
 import (
 	_ "github.com/mattn/go-sqlite3"
+	"time"
 )
 
 type Post struct {
-	Id         int
-	Username   string
-	Title      string
-	Categories []string
-	Content    string
-	CreatedAt  string
-	UpVotes    int
-	DownVotes  int
-	Comments   []Comment
+	Id          int
+	Username    string
+	Title       string
+	Categories  []string
+	Content     string
+	CreatedAt   time.Time
+	UpVotes     int
+	DownVotes   int
+	Comments    []Comment
+	Attachments []Attachment
 }
 
 type Comment struct {
 	Id        int
 	PostId    int
+	ParentId  int
+	Username  string
+	Content   string
+	CreatedAt time.Time
+}
+
+type Attachment struct {
+	Id           int
+	PostId       int
+	Username     string
+	Filename     string
+	MimeType     string
+	Size         int64
+	BlobKey      string
+	ThumbnailKey string
+	CreatedAt    string
+}
+
+type Notification struct {
+	Id        int
+	Username  string
+	Kind      string
+	Actor     string
+	PostId    int
+	CommentId int
+	Message   string
+	Read      bool
+	CreatedAt string
+}
+
+type EmailPreferences struct {
+	Username         string
+	Digest           bool
+	Replies          bool
+	UnsubscribeToken string
+	LastDigestAt     string
+}
+
+type FeedItem struct {
+	Kind      string
+	PostId    int
+	PostTitle string
+	CommentId int
 	Username  string
 	Content   string
 	CreatedAt string
 }
+
+type Bookmark struct {
+	Id        int    `json:"id"`
+	PostId    int    `json:"postId"`
+	PostTitle string `json:"postTitle"`
+	Folder    string `json:"folder"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type Report struct {
+	Id        int
+	Username  string
+	PostId    int
+	PostTitle string
+	CommentId int
+	Reason    string
+	Status    string
+	CreatedAt string
+}
+
+type Webhook struct {
+	Id         int
+	URL        string
+	Secret     string
+	Events     []string
+	Categories []string
+	Active     bool
+	CreatedBy  string
+	CreatedAt  string
+}
+
+type WebhookDelivery struct {
+	Id             int
+	WebhookId      int
+	WebhookURL     string
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int
+	LastStatusCode int
+	LastError      string
+	NextAttemptAt  string
+	CreatedAt      string
+	DeliveredAt    string
+}
+
+type Vote struct {
+	PostId    int
+	PostTitle string
+	Vote      int
+}
+
+// Stats are the numbers of rows of the forum
+type Stats struct {
+	Users           int
+	Admins          int
+	Banned          int
+	ActiveSessions  int
+	Categories      int
+	Posts           int
+	Comments        int
+	Votes           int
+	Attachments     int
+	AttachmentsSize int64
+	OpenReports     int
+	Webhooks        int
+	SchemaVersion   int
+}
+
+// UserSettings are the preferences of a user, the notifications of the kinds in MutedNotifications aren't sent
+type UserSettings struct {
+	DisplayName        string   `json:"displayName"`
+	Bio                string   `json:"bio"`
+	Timezone           string   `json:"timezone"`
+	Theme              string   `json:"theme"`
+	PostsPerPage       int      `json:"postsPerPage"`
+	DefaultSort        string   `json:"defaultSort"`
+	MutedNotifications []string `json:"mutedNotifications"`
+}
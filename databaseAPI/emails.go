@@ -0,0 +1,43 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+// GetEmailPreferences returns the email preferences of a user, the defaults are saved the first time:
+// the weekly digest is sent, reply emails are not
+func GetEmailPreferences(database *sql.DB, username string) (EmailPreferences, error) {
+	preferences := EmailPreferences{Username: username}
+	_, err := database.Exec("INSERT INTO email_preferences (username, digest, replies, unsubscribe_token, last_digest_at) VALUES (?, 1, 0, ?, '') ON CONFLICT DO NOTHING", username, uuid.NewV4().String())
+	if err != nil {
+		return preferences, err
+	}
+	err = database.QueryRow("SELECT digest, replies, unsubscribe_token, last_digest_at FROM email_preferences WHERE username = ?", username).
+		Scan(&preferences.Digest, &preferences.Replies, &preferences.UnsubscribeToken, &preferences.LastDigestAt)
+	return preferences, err
+}
+
+// SetEmailPreferences saves which emails a user wants to receive
+func SetEmailPreferences(database *sql.DB, username string, digest bool, replies bool) error {
+	if _, err := GetEmailPreferences(database, username); err != nil {
+		return err
+	}
+	_, err := database.Exec("UPDATE email_preferences SET digest = ?, replies = ? WHERE username = ?", digest, replies, username)
+	return err
+}
+
+// GetUsernameByUnsubscribeToken returns the user owning an unsubscribe token, ErrNotFound if no user owns it
+func GetUsernameByUnsubscribeToken(database *sql.DB, token string) (string, error) {
+	var username string
+	err := database.QueryRow("SELECT username FROM email_preferences WHERE unsubscribe_token = ?", token).Scan(&username)
+	return username, dbError(err)
+}
+
+// SetLastDigest saves when the last digest was sent to a user
+func SetLastDigest(database *sql.DB, username string, sentAt time.Time) error {
+	_, err := database.Exec("UPDATE email_preferences SET last_digest_at = ? WHERE username = ?", FormatTime(sentAt), username)
+	return err
+}
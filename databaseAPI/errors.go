@@ -0,0 +1,70 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrNotFound is returned when the row looked up, or the row to change, doesn't exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a row can't be added because it would duplicate an existing one
+	ErrConflict = errors.New("conflict")
+	// ErrConstraint is returned when a change breaks another constraint of the database
+	ErrConstraint = errors.New("constraint violated")
+	// ErrBanned is returned when the role of a banned user is changed otherwise than by lifting the ban
+	ErrBanned = errors.New("user is banned")
+)
+
+// dbError returns the error of the package matching an error of the database driver, wrapping it so that both can
+// be tested with errors.Is, or err itself when none matches
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		if sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		}
+		return fmt.Errorf("%w: %w", ErrConstraint, err)
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Class() == "23" {
+		if pqErr.Code == "23505" {
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		}
+		return fmt.Errorf("%w: %w", ErrConstraint, err)
+	}
+	return err
+}
+
+// execError returns the error of a statement changing rows, ErrNotFound if it changed none
+func execError(result sql.Result, err error) error {
+	if err != nil {
+		return dbError(err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// insertedId returns the id of the row added by a statement ending with RETURNING id, which both databases support
+// unlike LastInsertId
+func insertedId(row *sql.Row) (int, error) {
+	var id int
+	err := row.Scan(&id)
+	return id, dbError(err)
+}
@@ -0,0 +1,181 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	importPost    = "post"
+	importComment = "comment"
+)
+
+// defaultCategoryIcon is the icon of the categories created by an import
+const defaultCategoryIcon = "fa-folder"
+
+// Importer writes the data imported from another forum in a single transaction. The ids given by the source to posts
+// and comments are kept in the imports table, and users and categories are matched by name, so importing the same
+// data again changes nothing.
+type Importer struct {
+	tx     *sql.Tx
+	source string
+}
+
+// BeginImport starts an import from a source, the name under which the ids of the source are kept
+func BeginImport(database *sql.DB, source string) (*Importer, error) {
+	if source == "" {
+		return nil, errors.New("missing import source")
+	}
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Importer{tx: tx, source: source}, nil
+}
+
+// Commit recounts the votes of the posts and saves the import
+func (i *Importer) Commit() error {
+	if _, err := i.tx.Exec(recountVotesQuery); err != nil {
+		i.tx.Rollback()
+		return err
+	}
+	return i.tx.Commit()
+}
+
+// Rollback cancels the import
+func (i *Importer) Rollback() error {
+	return i.tx.Rollback()
+}
+
+// User adds a user unless the username is taken, it returns true if the user has been added. Without a bcrypt
+// password hash, the user can't log in until their password is reset. A missing or taken email is replaced by a
+// placeholder, since emails are unique.
+func (i *Importer) User(username string, email string, passwordHash string, admin bool) (bool, error) {
+	var count int
+	if err := i.tx.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", username).Scan(&count); err != nil {
+		return false, err
+	}
+	if count != 0 {
+		return false, nil
+	}
+	if email != "" {
+		if err := i.tx.QueryRow("SELECT COUNT(*) FROM users WHERE email = ?", email).Scan(&count); err != nil {
+			return false, err
+		}
+	}
+	if email == "" || count != 0 {
+		email = username + "@imported.invalid"
+	}
+	if !strings.HasPrefix(passwordHash, "$2") {
+		passwordHash = ""
+	}
+	role := "user"
+	if admin {
+		role = "admin"
+	}
+	_, err := i.tx.Exec("INSERT INTO users (username, email, password, cookie, expires, role) VALUES (?, ?, ?, '', '', ?)", username, email, passwordHash, role)
+	return err == nil, err
+}
+
+// Category adds a category unless it exists, it returns true if the category has been added
+func (i *Importer) Category(name string, icon string) (bool, error) {
+	if icon == "" {
+		icon = defaultCategoryIcon
+	}
+	result, err := i.tx.Exec("INSERT INTO categories (name, icon) SELECT ?, ? WHERE NOT EXISTS (SELECT 1 FROM categories WHERE name = ?)", name, icon, name)
+	if err != nil {
+		return false, err
+	}
+	added, err := result.RowsAffected()
+	return added != 0, err
+}
+
+// Post adds a post unless the post with this id in the source has already been imported, it returns true if the
+// post has been added
+func (i *Importer) Post(sourceId string, username string, title string, categories []string, content string, createdAt time.Time) (bool, error) {
+	_, found, err := i.localId(importPost, sourceId)
+	if err != nil || found {
+		return false, err
+	}
+	var id int
+	err = i.tx.QueryRow("INSERT INTO posts (username, title, categories, content, created_at, upvotes, downvotes) VALUES (?, ?, ?, ?, ?, 0, 0) RETURNING id",
+		username, title, strings.Join(categories, ","), content, FormatTime(createdAt)).Scan(&id)
+	if err != nil {
+		return false, err
+	}
+	return true, i.saveLocalId(importPost, sourceId, id)
+}
+
+// Comment adds a comment unless the comment with this id in the source has already been imported, it returns true if
+// the comment has been added. The post and the parent comment, if parentId isn't empty, must have been imported before.
+func (i *Importer) Comment(sourceId string, postId string, parentId string, username string, content string, createdAt time.Time) (bool, error) {
+	_, found, err := i.localId(importComment, sourceId)
+	if err != nil || found {
+		return false, err
+	}
+	localPostId, err := i.requireLocalId(importPost, postId)
+	if err != nil {
+		return false, err
+	}
+	localParentId := 0
+	if parentId != "" {
+		if localParentId, err = i.requireLocalId(importComment, parentId); err != nil {
+			return false, err
+		}
+	}
+	var id int
+	err = i.tx.QueryRow("INSERT INTO comments (username, post_id, parent_id, content, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		username, localPostId, localParentId, content, FormatTime(createdAt)).Scan(&id)
+	if err != nil {
+		return false, err
+	}
+	return true, i.saveLocalId(importComment, sourceId, id)
+}
+
+// Vote adds the vote of a user for an imported post unless they have already voted for it, it returns true if the
+// vote has been added
+func (i *Importer) Vote(postId string, username string, vote int) (bool, error) {
+	localPostId, err := i.requireLocalId(importPost, postId)
+	if err != nil {
+		return false, err
+	}
+	result, err := i.tx.Exec("INSERT INTO votes (username, post_id, vote) SELECT ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM votes WHERE username = ? AND post_id = ?)",
+		username, localPostId, vote, username, localPostId)
+	if err != nil {
+		return false, err
+	}
+	added, err := result.RowsAffected()
+	return added != 0, err
+}
+
+// localId returns the id in the forum of a post or a comment imported from the source
+func (i *Importer) localId(kind string, sourceId string) (int, bool, error) {
+	var id int
+	err := i.tx.QueryRow("SELECT local_id FROM imports WHERE source = ? AND kind = ? AND source_id = ?", i.source, kind, sourceId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+// requireLocalId returns the id in the forum of a post or a comment imported from the source, or an error if it
+// hasn't been imported
+func (i *Importer) requireLocalId(kind string, sourceId string) (int, error) {
+	id, found, err := i.localId(kind, sourceId)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, errors.New("unknown " + kind + " " + strconv.Quote(sourceId))
+	}
+	return id, nil
+}
+
+// saveLocalId keeps the id in the forum of a post or a comment that has just been inserted
+func (i *Importer) saveLocalId(kind string, sourceId string, id int) error {
+	_, err := i.tx.Exec("INSERT INTO imports (source, kind, source_id, local_id) VALUES (?, ?, ?, ?)", i.source, kind, sourceId, id)
+	return err
+}
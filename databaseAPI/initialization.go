@@ -3,76 +3,214 @@ package databaseAPI
 import (
 	"database/sql"
 	_ "github.com/mattn/go-sqlite3"
+	"strings"
 )
 
 // CreateUsersTable creates the users table
-func CreateUsersTable(database *sql.DB) {
-	statement, _ := database.Prepare("CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY, username TEXT, email TEXT, password TEXT, cookie TEXT, expires TEXT)")
-	statement.Exec()
+func CreateUsersTable(database *sql.DB) error {
+	if _, err := createTable(database, "CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY, username TEXT, email TEXT, password TEXT, cookie TEXT, expires TEXT, role TEXT DEFAULT 'user')"); err != nil {
+		return err
+	}
+	_, err := addColumn(database, "users", "role", "TEXT DEFAULT 'user'")
+	return err
 }
 
 // CreatePostTable create post table
-func CreatePostTable(database *sql.DB) {
-	statement, _ := database.Prepare("CREATE TABLE IF NOT EXISTS posts (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT, title TEXT, categories TEXT, content TEXT, created_at TEXT, upvotes INTEGER, downvotes INTEGER)")
-	statement.Exec()
+func CreatePostTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS posts (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT, title TEXT, categories TEXT, content TEXT, created_at TEXT, upvotes INTEGER, downvotes INTEGER)")
+	return err
 }
 
 // CreateCommentTable creates a comment table
-func CreateCommentTable(database *sql.DB) {
-	statement, _ := database.Prepare("CREATE TABLE IF NOT EXISTS comments (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT, post_id INTEGER, content TEXT, created_at TEXT)")
-	statement.Exec()
+func CreateCommentTable(database *sql.DB) error {
+	if _, err := createTable(database, "CREATE TABLE IF NOT EXISTS comments (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT, post_id INTEGER, content TEXT, created_at TEXT, parent_id INTEGER DEFAULT 0)"); err != nil {
+		return err
+	}
+	_, err := addColumn(database, "comments", "parent_id", "INTEGER DEFAULT 0")
+	return err
 }
 
 // CreateVoteTable create the vote table into given database
-func CreateVoteTable(database *sql.DB) {
-	statement, _ := database.Prepare("CREATE TABLE IF NOT EXISTS votes (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT, post_id INTEGER, vote INTEGER)")
-	statement.Exec()
+func CreateVoteTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS votes (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT, post_id INTEGER, vote INTEGER)")
+	return err
+}
+
+// CreateAttachmentTable creates the table of files attached to posts
+func CreateAttachmentTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS attachments (id INTEGER PRIMARY KEY AUTOINCREMENT, post_id INTEGER, username TEXT, filename TEXT, mime_type TEXT, size INTEGER, blob_key TEXT, thumbnail_key TEXT, created_at TEXT)")
+	return err
+}
+
+// CreateNotificationTable creates the table of the users' notifications
+func CreateNotificationTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS notifications (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT, kind TEXT, actor TEXT, post_id INTEGER, comment_id INTEGER, message TEXT, read INTEGER DEFAULT 0, created_at TEXT)")
+	return err
+}
+
+// CreateSubscriptionTable creates the table of what users follow
+func CreateSubscriptionTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS subscriptions (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT, kind TEXT, target TEXT, created_at TEXT, UNIQUE (username, kind, target))")
+	return err
+}
+
+// CreateBookmarkTable creates the table of the posts saved by users, the folder is optional
+func CreateBookmarkTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS bookmarks (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT, post_id INTEGER, folder TEXT DEFAULT '', created_at TEXT, UNIQUE (username, post_id))")
+	return err
+}
+
+// CreateReportTable creates the table of the posts and comments reported to the admins
+func CreateReportTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS reports (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT, post_id INTEGER, comment_id INTEGER DEFAULT 0, reason TEXT, status TEXT DEFAULT 'open', created_at TEXT)")
+	return err
+}
+
+// CreateWebhookTables creates the tables of the webhooks and of their deliveries
+func CreateWebhookTables(database *sql.DB) error {
+	if _, err := createTable(database, "CREATE TABLE IF NOT EXISTS webhooks (id INTEGER PRIMARY KEY AUTOINCREMENT, url TEXT, secret TEXT, events TEXT, categories TEXT, active INTEGER DEFAULT 1, created_by TEXT, created_at TEXT)"); err != nil {
+		return err
+	}
+	if _, err := createTable(database, "CREATE TABLE IF NOT EXISTS webhook_deliveries (id INTEGER PRIMARY KEY AUTOINCREMENT, webhook_id INTEGER, event TEXT, payload TEXT, status TEXT, attempts INTEGER DEFAULT 0, last_status_code INTEGER DEFAULT 0, last_error TEXT DEFAULT '', next_attempt_at TEXT, created_at TEXT, delivered_at TEXT DEFAULT '', username TEXT DEFAULT '', post_id INTEGER DEFAULT 0)"); err != nil {
+		return err
+	}
+	usernameAdded, err := addColumn(database, "webhook_deliveries", "username", "TEXT DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	postIdAdded, err := addColumn(database, "webhook_deliveries", "post_id", "INTEGER DEFAULT 0")
+	if err != nil || !usernameAdded && !postIdAdded {
+		return err
+	}
+	// the deliveries saved before hold the user and the post only in their payload, the post being the subject of the
+	// post events
+	query := "UPDATE webhook_deliveries SET username = COALESCE(json_extract(payload, '$.data.username'), ''), post_id = COALESCE(json_extract(payload, CASE WHEN event = 'post.created' THEN '$.data.id' ELSE '$.data.postId' END), 0) WHERE json_valid(payload)"
+	if isPostgres(database) {
+		query = "UPDATE webhook_deliveries SET username = COALESCE(payload::jsonb #>> '{data,username}', ''), post_id = COALESCE(CAST(payload::jsonb #>> CASE WHEN event = 'post.created' THEN '{data,id}' ELSE '{data,postId}' END AS BIGINT), 0)"
+	}
+	_, err = database.Exec(query)
+	return err
+}
+
+// CreateImportTable creates the table of the ids given by other forums to the data imported from them
+func CreateImportTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS imports (source TEXT, kind TEXT, source_id TEXT, local_id INTEGER, PRIMARY KEY (source, kind, source_id))")
+	return err
+}
+
+// CreateEmailPreferencesTable creates the table of the users' email preferences
+func CreateEmailPreferencesTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS email_preferences (username TEXT PRIMARY KEY, digest INTEGER, replies INTEGER, unsubscribe_token TEXT UNIQUE, last_digest_at TEXT)")
+	return err
+}
+
+// CreateUserSettingsTable creates the table of the users' settings
+func CreateUserSettingsTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS user_settings (username TEXT PRIMARY KEY, display_name TEXT, bio TEXT, timezone TEXT, theme TEXT, posts_per_page INTEGER, default_sort TEXT, muted_notifications TEXT)")
+	return err
+}
+
+// CreateTables creates the missing tables of the features beyond the store, such as the notifications and the
+// webhooks, on a SQLite or a PostgreSQL database whose store tables are created
+func CreateTables(database *sql.DB) error {
+	for _, create := range []func(*sql.DB) error{
+		CreateAttachmentTable,
+		CreateNotificationTable,
+		CreateSubscriptionTable,
+		CreateEmailPreferencesTable,
+		CreateUserSettingsTable,
+		CreateBookmarkTable,
+		CreateReportTable,
+		CreateWebhookTables,
+		CreateImportTable,
+	} {
+		if err := create(database); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // CreateCategoriesTable create the categories' table into given database
-func CreateCategoriesTable(database *sql.DB) {
-	statement, _ := database.Prepare("CREATE TABLE IF NOT EXISTS categories (id INTEGER PRIMARY KEY, name TEXT, icon TEXT)")
-	statement.Exec()
+func CreateCategoriesTable(database *sql.DB) error {
+	_, err := createTable(database, "CREATE TABLE IF NOT EXISTS categories (id INTEGER PRIMARY KEY, name TEXT, icon TEXT)")
+	return err
+}
+
+// defaultCategories are the categories of a new forum, with their icons
+var defaultCategories = [][2]string{
+	{"General", "fa-globe"},
+	{"Technology", "fa-laptop"},
+	{"Science", "fa-flask"},
+	{"Sports", "fa-futbol-o"},
+	{"Gaming", "fa-gamepad"},
+	{"Music", "fa-music"},
+	{"Books", "fa-book"},
+	{"Movies", "fa-film"},
+	{"TV", "fa-tv"},
+	{"Food", "fa-cutlery"},
+	{"Travel", "fa-plane"},
+	{"Photography", "fa-camera"},
+	{"Art", "fa-paint-brush"},
+	{"Writing", "fa-pencil"},
+	{"Programming", "fa-code"},
+	{"Other", "fa-question"},
 }
 
 // CreateCategories creates categories in the database
-func CreateCategories(database *sql.DB) {
-	statement, _ := database.Prepare("INSERT INTO categories (name) SELECT ? WHERE NOT EXISTS (SELECT 1 FROM categories WHERE name = ?)")
-	statement.Exec("General", "General")
-	statement.Exec("Technology", "Technology")
-	statement.Exec("Science", "Science")
-	statement.Exec("Sports", "Sports")
-	statement.Exec("Gaming", "Gaming")
-	statement.Exec("Music", "Music")
-	statement.Exec("Books", "Books")
-	statement.Exec("Movies", "Movies")
-	statement.Exec("TV", "TV")
-	statement.Exec("Food", "Food")
-	statement.Exec("Travel", "Travel")
-	statement.Exec("Photography", "Photography")
-	statement.Exec("Art", "Art")
-	statement.Exec("Writing", "Writing")
-	statement.Exec("Programming", "Programming")
-	statement.Exec("Other", "Other")
-}
-
-// createCategoriesIcons creates categories' icons in the database
-func CreateCategoriesIcons(database *sql.DB) {
-	statement, _ := database.Prepare("UPDATE categories SET icon = ? WHERE name = ?")
-	statement.Exec("fa-globe", "General")
-	statement.Exec("fa-laptop", "Technology")
-	statement.Exec("fa-flask", "Science")
-	statement.Exec("fa-futbol-o", "Sports")
-	statement.Exec("fa-gamepad", "Gaming")
-	statement.Exec("fa-music", "Music")
-	statement.Exec("fa-book", "Books")
-	statement.Exec("fa-film", "Movies")
-	statement.Exec("fa-tv", "TV")
-	statement.Exec("fa-cutlery", "Food")
-	statement.Exec("fa-plane", "Travel")
-	statement.Exec("fa-camera", "Photography")
-	statement.Exec("fa-paint-brush", "Art")
-	statement.Exec("fa-pencil", "Writing")
-	statement.Exec("fa-code", "Programming")
-	statement.Exec("fa-question", "Other")
+func CreateCategories(database *sql.DB) error {
+	statement, err := database.Prepare("INSERT INTO categories (name) SELECT ? WHERE NOT EXISTS (SELECT 1 FROM categories WHERE name = ?)")
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+	for _, category := range defaultCategories {
+		if _, err := statement.Exec(category[0], category[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateCategoriesIcons creates categories' icons in the database
+func CreateCategoriesIcons(database *sql.DB) error {
+	statement, err := database.Prepare("UPDATE categories SET icon = ? WHERE name = ?")
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+	for _, category := range defaultCategories {
+		if _, err := statement.Exec(category[1], category[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTable runs a CREATE TABLE statement written for SQLite, whose integer primary key becomes a BIGSERIAL on
+// PostgreSQL
+func createTable(database *sql.DB, statement string) (sql.Result, error) {
+	if isPostgres(database) {
+		statement = strings.Replace(statement, "INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY", 1)
+		statement = strings.Replace(statement, "INTEGER PRIMARY KEY", "BIGSERIAL PRIMARY KEY", 1)
+	}
+	return database.Exec(statement)
+}
+
+// addColumn adds a column to a table created by an older version of the forum, if it doesn't have it yet. It returns
+// true if the column has been added.
+func addColumn(database *sql.DB, table string, column string, definition string) (bool, error) {
+	query := "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?"
+	if isPostgres(database) {
+		query = "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?"
+	}
+	var count int
+	if err := database.QueryRow(query, table, column).Scan(&count); err != nil {
+		return false, err
+	}
+	if count != 0 {
+		return false, nil
+	}
+	_, err := database.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err == nil, err
 }
@@ -0,0 +1,81 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"time"
+)
+
+// timeColumns are the columns saving times, by table
+var timeColumns = [][2]string{
+	{"users", "expires"},
+	{"posts", "created_at"},
+	{"comments", "created_at"},
+	{"attachments", "created_at"},
+	{"notifications", "created_at"},
+	{"subscriptions", "created_at"},
+	{"bookmarks", "created_at"},
+	{"reports", "created_at"},
+	{"webhooks", "created_at"},
+	{"webhook_deliveries", "next_attempt_at"},
+	{"webhook_deliveries", "created_at"},
+	{"webhook_deliveries", "delivered_at"},
+	{"email_preferences", "last_digest_at"},
+}
+
+// Migrate migrates the rows of a database created by an older version of the forum, once its tables are created, and
+// saves the schema version of the forum in it
+func Migrate(database *sql.DB) error {
+	version, err := GetSchemaVersion(database)
+	if err != nil {
+		return err
+	}
+	// the PostgreSQL databases have always saved the times in UTC
+	if version < 2 && !isPostgres(database) {
+		if err := migrateTimes(database); err != nil {
+			return err
+		}
+	}
+	return SetSchemaVersion(database)
+}
+
+// migrateTimes saves in UTC the times saved in the local time of the server before the schema version 2
+func migrateTimes(database *sql.DB) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, column := range timeColumns {
+		table, name := column[0], column[1]
+		rows, err := tx.Query("SELECT rowid, " + name + " FROM " + table + " WHERE " + name + " LIKE '____-__-__ __:__:__'")
+		if err != nil {
+			return err
+		}
+		times := map[int64]string{}
+		for rows.Next() {
+			var rowid int64
+			var value string
+			if err := rows.Scan(&rowid, &value); err != nil {
+				rows.Close()
+				return err
+			}
+			t, err := time.ParseInLocation(legacyTimeLayout, value, time.Local)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			times[rowid] = FormatTime(t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for rowid, value := range times {
+			if _, err := tx.Exec("UPDATE "+table+" SET "+name+" = ? WHERE rowid = ?", value, rowid); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
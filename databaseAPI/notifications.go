@@ -0,0 +1,58 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"time"
+)
+
+// AddNotification adds a notification for a user
+func AddNotification(database *sql.DB, notification Notification, createdAt time.Time) (int, error) {
+	createdAtString := FormatTime(createdAt)
+	return insertedId(database.QueryRow("INSERT INTO notifications (username, kind, actor, post_id, comment_id, message, read, created_at) VALUES (?, ?, ?, ?, ?, ?, 0, ?) RETURNING id",
+		notification.Username, notification.Kind, notification.Actor, notification.PostId, notification.CommentId, notification.Message, createdAtString))
+}
+
+// NotificationExists returns true if the user already got a notification of this kind and message for a post
+func NotificationExists(database *sql.DB, username string, kind string, postId int, message string) (bool, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM notifications WHERE username = ? AND kind = ? AND post_id = ? AND message = ?", username, kind, postId, message).Scan(&count)
+	return count > 0, err
+}
+
+// GetNotifications returns the latest notifications of a user, newest first
+func GetNotifications(database *sql.DB, username string, limit int) ([]Notification, error) {
+	rows, err := database.Query("SELECT id, username, kind, actor, post_id, comment_id, message, read, created_at FROM notifications WHERE username = ? ORDER BY id DESC LIMIT ?", username, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var notifications []Notification
+	for rows.Next() {
+		var notification Notification
+		if err := rows.Scan(&notification.Id, &notification.Username, &notification.Kind, &notification.Actor, &notification.PostId, &notification.CommentId, &notification.Message, &notification.Read, &notification.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+// CountUnreadNotifications returns the number of unread notifications of a user
+func CountUnreadNotifications(database *sql.DB, username string) (int, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM notifications WHERE username = ? AND read = 0", username).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead marks a notification of a user as read
+func MarkNotificationRead(database *sql.DB, username string, id int) error {
+	_, err := database.Exec("UPDATE notifications SET read = 1 WHERE id = ? AND username = ?", id, username)
+	return err
+}
+
+// MarkAllNotificationsRead marks all the notifications of a user as read
+func MarkAllNotificationsRead(database *sql.DB, username string) error {
+	_, err := database.Exec("UPDATE notifications SET read = 1 WHERE username = ? AND read = 0", username)
+	return err
+}
@@ -0,0 +1,241 @@
+package databaseAPI
+
+import (
+	"FORUM-GO/metricsAPI"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ObservedDriver is the name of the SQLite driver whose queries are timed in the metrics
+const ObservedDriver = "sqlite3-observed"
+
+// ObservedPostgresDriver is the name of the PostgreSQL driver whose queries are timed in the metrics. It takes the ?
+// placeholders and the booleans of SQLite, so that the functions of the package work on both databases.
+const ObservedPostgresDriver = "postgres-observed"
+
+func init() {
+	sql.Register(ObservedDriver, observedDriver{Driver: &sqlite3.SQLiteDriver{}})
+	sql.Register(ObservedPostgresDriver, observedDriver{Driver: &pq.Driver{}, postgres: true})
+}
+
+// isPostgres returns true if a database is a PostgreSQL database
+func isPostgres(database *sql.DB) bool {
+	switch d := database.Driver().(type) {
+	case observedDriver:
+		return d.postgres
+	case *pq.Driver:
+		return true
+	}
+	return false
+}
+
+// rebind returns a query with the ? placeholders of SQLite numbered like the ones of PostgreSQL, $1, $2 and so on.
+// The strings, the quoted names, the comments and the dollar-quoted bodies are left as they are, as are the ?| and ?&
+// operators of jsonb. Its ? operator can't be told apart from a placeholder, jsonb_exists is used instead.
+func rebind(query string) string {
+	var builder strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		end := i
+		switch {
+		case c == '\'' || c == '"':
+			end = closing(query, i+1, query[i:i+1])
+		case strings.HasPrefix(query[i:], "--"):
+			end = closing(query, i+2, "\n")
+		case strings.HasPrefix(query[i:], "/*"):
+			end = closing(query, i+2, "*/")
+		case c == '$':
+			if tag := dollarTag(query[i:]); tag != "" {
+				end = closing(query, i+len(tag), tag)
+			}
+		case c == '?' && (i+1 == len(query) || (query[i+1] != '|' && query[i+1] != '&')):
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteString(query[i : end+1])
+		i = end
+	}
+	return builder.String()
+}
+
+// closing returns the index of the last byte of the first terminator of a query after from, or of the last byte of
+// the query when an unterminated string, comment or body lasts until its end
+func closing(query string, from int, terminator string) int {
+	if index := strings.Index(query[from:], terminator); index >= 0 {
+		return from + index + len(terminator) - 1
+	}
+	return len(query) - 1
+}
+
+// dollarTag returns the tag starting a dollar-quoted body at the start of a query, such as $$ or $body$, or an empty
+// string for a numbered placeholder or any other dollar
+func dollarTag(query string) string {
+	for i := 1; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '$':
+			return query[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// postgresArgs returns the arguments of a query with the booleans saved as integers, like SQLite does
+func postgresArgs(args []driver.NamedValue) []driver.NamedValue {
+	converted := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		if b, ok := arg.Value.(bool); ok {
+			arg.Value = int64(0)
+			if b {
+				arg.Value = int64(1)
+			}
+		}
+		converted[i] = arg
+	}
+	return converted
+}
+
+// observe observes the duration of a query since start
+func observe(query string, start time.Time) {
+	metricsAPI.QueryDuration.WithLabelValues(operation(query)).Observe(time.Since(start).Seconds())
+}
+
+// operation returns the first keyword of a query in lower case, such as select or insert
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	switch keyword := strings.ToLower(fields[0]); keyword {
+	case "select", "insert", "update", "delete", "create", "alter", "pragma", "vacuum", "with":
+		return keyword
+	}
+	return "other"
+}
+
+// observedDriver wraps a driver to time the queries of its connections
+type observedDriver struct {
+	driver.Driver
+	postgres bool
+}
+
+func (d observedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return observedConn{conn, d.postgres}, nil
+}
+
+type observedConn struct {
+	driver.Conn
+	postgres bool
+}
+
+func (c observedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c observedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if c.postgres {
+		query = rebind(query)
+	}
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return observedStmt{stmt, query, c.postgres}, nil
+}
+
+func (c observedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if c.postgres {
+		query, args = rebind(query), postgresArgs(args)
+	}
+	defer observe(query, time.Now())
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c observedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if c.postgres {
+		query, args = rebind(query), postgresArgs(args)
+	}
+	defer observe(query, time.Now())
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c observedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c observedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+type observedStmt struct {
+	driver.Stmt
+	query    string
+	postgres bool
+}
+
+func (s observedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if s.postgres {
+		args = postgresArgs(args)
+	}
+	defer observe(s.query, time.Now())
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	return s.Stmt.Exec(values(args))
+}
+
+func (s observedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if s.postgres {
+		args = postgresArgs(args)
+	}
+	defer observe(s.query, time.Now())
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	return s.Stmt.Query(values(args))
+}
+
+// values returns the values of named arguments, for the drivers without context
+func values(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
@@ -0,0 +1,34 @@
+package databaseAPI
+
+import (
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"no placeholder", "SELECT 1", "SELECT 1"},
+		{"placeholders", "SELECT id FROM posts WHERE username = ? AND id > ? LIMIT ?", "SELECT id FROM posts WHERE username = $1 AND id > $2 LIMIT $3"},
+		{"string", "SELECT '?' || ? FROM posts WHERE title = 'a ? b'", "SELECT '?' || $1 FROM posts WHERE title = 'a ? b'"},
+		{"escaped quote", "SELECT 'it''s ?', ?", "SELECT 'it''s ?', $1"},
+		{"backslash", `SELECT REPLACE(?, '\', '\\') LIKE ? ESCAPE '\'`, `SELECT REPLACE($1, '\', '\\') LIKE $2 ESCAPE '\'`},
+		{"quoted name", `SELECT "a?" FROM posts WHERE id = ?`, `SELECT "a?" FROM posts WHERE id = $1`},
+		{"unterminated string", "SELECT ? || 'a ?", "SELECT $1 || 'a ?"},
+		{"line comment", "SELECT ? -- why ?\nFROM posts WHERE id = ?", "SELECT $1 -- why ?\nFROM posts WHERE id = $2"},
+		{"block comment", "SELECT /* a ? */ ?", "SELECT /* a ? */ $1"},
+		{"dollar-quoted body", "SELECT $$a ? 'b'$$, $body$ ? $$ $body$, ?", "SELECT $$a ? 'b'$$, $body$ ? $$ $body$, $1"},
+		{"jsonb operators", "SELECT data ?| ARRAY['a'], data ?& ARRAY['b'] FROM t WHERE id = ?", "SELECT data ?| ARRAY['a'], data ?& ARRAY['b'] FROM t WHERE id = $1"},
+		{"concatenation", "SELECT ? || ?", "SELECT $1 || $2"},
+		{"numbered placeholders", "SELECT $1, $2", "SELECT $1, $2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := rebind(test.query); got != test.want {
+				t.Errorf("rebind(%q) = %q, want %q", test.query, got, test.want)
+			}
+		})
+	}
+}
@@ -8,128 +8,210 @@ import (
 	"time"
 )
 
-// GetPost by id returns a Post struct with the post data
-func GetPost(database *sql.DB, id string) Post {
-	rows, _ := database.Query("SELECT username, title, categories, content, created_at, upvotes, downvotes FROM posts WHERE id = ?", id)
-	var post Post
-	post.Id, _ = strconv.Atoi(id)
-	for rows.Next() {
-		catString := ""
-		rows.Scan(&post.Username, &post.Title, &catString, &post.Content, &post.CreatedAt, &post.UpVotes, &post.DownVotes)
-		categoriesArray := strings.Split(catString, ",")
-		post.Categories = categoriesArray
+// GetPost by id returns a Post struct with the post data, ErrNotFound if it doesn't exist
+func GetPost(database *sql.DB, id string) (Post, error) {
+	postId, ok := parseId(id)
+	if !ok {
+		return Post{}, ErrNotFound
 	}
-	return post
+	return scanPost(database.QueryRow("SELECT id, username, title, categories, content, created_at, upvotes, downvotes FROM posts WHERE id = ?", postId))
 }
 
 // GetComments get comments by post id
-func GetComments(database *sql.DB, id string) []Comment {
-	rows, _ := database.Query("SELECT id, username, content, created_at FROM comments WHERE post_id = ?", id)
+func GetComments(database *sql.DB, id string) ([]Comment, error) {
+	postId, ok := parseId(id)
+	if !ok {
+		return nil, nil
+	}
+	rows, err := database.Query("SELECT id, parent_id, username, content, created_at FROM comments WHERE post_id = ?", postId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 	var comments []Comment
 	for rows.Next() {
 		var comment Comment
-		rows.Scan(&comment.Id, &comment.Username, &comment.Content, &comment.CreatedAt)
+		if err := rows.Scan(&comment.Id, &comment.ParentId, &comment.Username, &comment.Content, scanTime(&comment.CreatedAt)); err != nil {
+			return nil, err
+		}
 		comments = append(comments, comment)
 	}
-	return comments
+	return comments, rows.Err()
 }
 
 // GetPostsByCategory returns all posts in a given category
-func GetPostsByCategory(database *sql.DB, category string) []Post {
-	rows, _ := database.Query("SELECT id, username, title, categories, content, created_at, upvotes, downvotes  FROM posts WHERE categories LIKE ?", "%"+category+"%")
-	var posts []Post
-	for rows.Next() {
-		var post Post
-		var catString string
-		rows.Scan(&post.Id, &post.Username, &post.Title, &catString, &post.Content, &post.CreatedAt, &post.UpVotes, &post.DownVotes)
-		post.Categories = strings.Split(catString, ",")
-		posts = append(posts, post)
-	}
-	return posts
+func GetPostsByCategory(database *sql.DB, category string) ([]Post, error) {
+	return scanPosts(database.Query("SELECT id, username, title, categories, content, created_at, upvotes, downvotes  FROM posts WHERE "+inCategory, category))
 }
 
 // GetPostsByCategories returns all posts for all categories
-func GetPostsByCategories(database *sql.DB) [][]Post {
-	categories := GetCategories(database)
+func GetPostsByCategories(database *sql.DB) ([][]Post, error) {
+	categories, err := GetCategories(database)
+	if err != nil {
+		return nil, err
+	}
 	var posts [][]Post
 	for _, category := range categories {
-		posts = append(posts, GetPostsByCategory(database, category))
+		categoryPosts, err := GetPostsByCategory(database, category)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, categoryPosts)
 	}
-	return posts
+	return posts, nil
 }
 
 // GetPostsByUser returns all posts by a user
-func GetPostsByUser(database *sql.DB, username string) []Post {
-	rows, _ := database.Query("SELECT id, username, title, categories, content, created_at, upvotes, downvotes  FROM posts WHERE username = ?", username)
-	var posts []Post
-	for rows.Next() {
-		var post Post
-		var catString string
-		rows.Scan(&post.Id, &post.Username, &post.Title, &catString, &post.Content, &post.CreatedAt, &post.UpVotes, &post.DownVotes)
-		post.Categories = strings.Split(catString, ",")
-		posts = append(posts, post)
-	}
-	return posts
+func GetPostsByUser(database *sql.DB, username string) ([]Post, error) {
+	return scanPosts(database.Query("SELECT id, username, title, categories, content, created_at, upvotes, downvotes  FROM posts WHERE username = ?", username))
 }
 
 // GetLikedPosts gets posts that user has liked
-func GetLikedPosts(database *sql.DB, username string) []Post {
-	rows, _ := database.Query("SELECT id, username, title, categories, content, created_at, upvotes, downvotes  FROM posts WHERE id IN (SELECT post_id FROM votes WHERE username = ? AND vote = 1)", username)
-	var posts []Post
-	for rows.Next() {
-		var post Post
-		var catString string
-		rows.Scan(&post.Id, &post.Username, &post.Title, &catString, &post.Content, &post.CreatedAt, &post.UpVotes, &post.DownVotes)
-		post.Categories = strings.Split(catString, ",")
-		posts = append(posts, post)
-	}
-	return posts
+func GetLikedPosts(database *sql.DB, username string) ([]Post, error) {
+	return scanPosts(database.Query("SELECT id, username, title, categories, content, created_at, upvotes, downvotes  FROM posts WHERE id IN (SELECT post_id FROM votes WHERE username = ? AND vote = 1)", username))
 }
 
 // GetCategories returns all categories
-func GetCategories(database *sql.DB) []string {
-	rows, _ := database.Query("SELECT name FROM categories")
-	var categories []string
-	for rows.Next() {
-		var name string
-		rows.Scan(&name)
-		categories = append(categories, name)
-	}
-	return categories
+func GetCategories(database *sql.DB) ([]string, error) {
+	return scanStrings(database.Query("SELECT name FROM categories"))
 }
 
 // GetCategoriesIcons returns all categories' icons
-func GetCategoriesIcons(database *sql.DB) []string {
-	rows, _ := database.Query("SELECT icon FROM categories")
-	var icons []string
-	for rows.Next() {
-		var icon string
-		rows.Scan(&icon)
-		icons = append(icons, icon)
-	}
-	return icons
+func GetCategoriesIcons(database *sql.DB) ([]string, error) {
+	return scanStrings(database.Query("SELECT icon FROM categories"))
 }
 
-// GetCategoryIcon returns the icon for a category
-func GetCategoryIcon(database *sql.DB, category string) string {
-	rows, _ := database.Query("SELECT icon FROM categories WHERE name = ?", category)
+// GetCategoryIcon returns the icon for a category, ErrNotFound if the category doesn't exist
+func GetCategoryIcon(database *sql.DB, category string) (string, error) {
 	var icon string
-	for rows.Next() {
-		rows.Scan(&icon)
+	err := database.QueryRow("SELECT icon FROM categories WHERE name = ?", category).Scan(&icon)
+	return icon, dbError(err)
+}
+
+// CreatePost creates a post with its attachments, whose blobs must be stored already, and returns its id. The post
+// isn't created if an attachment can't be added.
+func CreatePost(database *sql.DB, username string, title string, categories string, content string, attachments []Attachment, createdAt time.Time) (int, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	createdAtString := FormatTime(createdAt)
+	id, err := insertedId(tx.QueryRow("INSERT INTO posts (username, title, categories, content, created_at, upvotes, downvotes) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id", username, title, categories, content, createdAtString, 0, 0))
+	if err != nil {
+		return 0, err
+	}
+	for _, attachment := range attachments {
+		attachment.PostId = id
+		if err := addAttachment(tx, attachment, createdAt); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// GetComment returns a comment by id, ErrNotFound if it doesn't exist
+func GetComment(database *sql.DB, id int) (Comment, error) {
+	var comment Comment
+	err := database.QueryRow("SELECT id, post_id, parent_id, username, content, created_at FROM comments WHERE id = ?", id).
+		Scan(&comment.Id, &comment.PostId, &comment.ParentId, &comment.Username, &comment.Content, scanTime(&comment.CreatedAt))
+	return comment, dbError(err)
+}
+
+// AddComment adds a comment to a post, parentId is the id of the comment it replies to or 0, returns its id
+func AddComment(database *sql.DB, username string, postId int, parentId int, content string, createdAt time.Time) (int, error) {
+	createdAtString := FormatTime(createdAt)
+	return insertedId(database.QueryRow("INSERT INTO comments (username, post_id, parent_id, content, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id", username, postId, parentId, content, createdAtString))
+}
+
+// GetTopPostsInCategories returns the posts created since the given time in any of the categories, best score first
+func GetTopPostsInCategories(database *sql.DB, categories []string, since time.Time, limit int) ([]Post, error) {
+	rows, err := database.Query("SELECT id, username, title, categories, content, created_at, upvotes, downvotes FROM posts WHERE created_at >= ? ORDER BY upvotes - downvotes DESC, id DESC", FormatTime(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var posts []Post
+	for rows.Next() && len(posts) < limit {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, category := range post.Categories {
+			if containsString(categories, category) {
+				posts = append(posts, post)
+				break
+			}
+		}
 	}
-	return icon
+	return posts, rows.Err()
+}
+
+// GetLatestPosts returns the latest posts, only the ones in a category and by a user when they aren't empty
+func GetLatestPosts(database *sql.DB, category string, username string, limit int) ([]Post, error) {
+	return scanPosts(database.Query(`SELECT id, username, title, categories, content, created_at, upvotes, downvotes FROM posts
+		WHERE (? = '' OR `+inCategory+`) AND (? = '' OR username = ?)
+		ORDER BY created_at DESC, id DESC LIMIT ?`, category, category, username, username, limit))
 }
 
-// CreatePost
-func CreatePost(database *sql.DB, username string, title string, categories string, content string, createdAt time.Time) {
-	createdAtString := createdAt.Format("2006-01-02 15:04:05")
-	statement, _ := database.Prepare("INSERT INTO posts (username, title, categories, content, created_at, upvotes, downvotes) VALUES (?, ?, ?, ?, ?, ?, ?)")
-	statement.Exec(username, title, categories, content, createdAtString, 0, 0)
+// containsString returns true if the string is in the array
+func containsString(array []string, input string) bool {
+	for _, value := range array {
+		if value == input {
+			return true
+		}
+	}
+	return false
 }
 
-// AddComment adds a comment to a post
-func AddComment(database *sql.DB, username string, postId int, content string, createdAt time.Time) {
-	createdAtString := createdAt.Format("2006-01-02 15:04:05")
-	statement, _ := database.Prepare("INSERT INTO comments (username, post_id, content, created_at) VALUES (?, ?, ?, ?)")
-	statement.Exec(username, postId, content, createdAtString)
+// parseId returns the id given as a string to the functions of the package, false if it isn't an integer: no row has
+// such an id, and PostgreSQL refuses to compare it to an integer column
+func parseId(id string) (int, bool) {
+	value, err := strconv.Atoi(id)
+	return value, err == nil
+}
+
+// scanPost returns the post of a row selecting the columns of Post, ErrNotFound if there is no row
+func scanPost(row interface{ Scan(...interface{}) error }) (Post, error) {
+	var post Post
+	var catString string
+	if err := row.Scan(&post.Id, &post.Username, &post.Title, &catString, &post.Content, scanTime(&post.CreatedAt), &post.UpVotes, &post.DownVotes); err != nil {
+		return post, dbError(err)
+	}
+	post.Categories = strings.Split(catString, ",")
+	return post, nil
+}
+
+// scanPosts returns the posts of rows selecting the columns of Post, and closes them
+func scanPosts(rows *sql.Rows, err error) ([]Post, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var posts []Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// scanStrings returns the strings of rows selecting one column, and closes them
+func scanStrings(rows *sql.Rows, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
 }
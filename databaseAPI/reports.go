@@ -0,0 +1,36 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"time"
+)
+
+// AddReport saves a report of a post, or of one of its comments if commentId isn't 0, and returns its id
+func AddReport(database *sql.DB, username string, postId int, commentId int, reason string, createdAt time.Time) (int, error) {
+	createdAtString := FormatTime(createdAt)
+	return insertedId(database.QueryRow("INSERT INTO reports (username, post_id, comment_id, reason, status, created_at) VALUES (?, ?, ?, ?, 'open', ?) RETURNING id", username, postId, commentId, reason, createdAtString))
+}
+
+// GetReports returns the reports with a status, latest first
+func GetReports(database *sql.DB, status string) ([]Report, error) {
+	rows, err := database.Query("SELECT r.id, r.username, r.post_id, COALESCE(p.title, ''), r.comment_id, r.reason, r.status, r.created_at FROM reports r LEFT JOIN posts p ON p.id = r.post_id WHERE r.status = ? ORDER BY r.id DESC", status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var reports []Report
+	for rows.Next() {
+		var report Report
+		if err := rows.Scan(&report.Id, &report.Username, &report.PostId, &report.PostTitle, &report.CommentId, &report.Reason, &report.Status, &report.CreatedAt); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// CloseReport marks a report as handled, ErrNotFound if it doesn't exist
+func CloseReport(database *sql.DB, id int) error {
+	return execError(database.Exec("UPDATE reports SET status = 'closed' WHERE id = ?", id))
+}
@@ -0,0 +1,76 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"strings"
+)
+
+// DefaultUserSettings returns the settings of the users who didn't change them: the time zone of the server, the
+// light theme, 20 posts per page, the oldest posts first and all the notifications
+func DefaultUserSettings() UserSettings {
+	return UserSettings{Theme: "light", PostsPerPage: 20, DefaultSort: "oldest"}
+}
+
+// GetUserSettings returns the settings of a user, the default ones if they never saved them
+func GetUserSettings(database *sql.DB, username string) (UserSettings, error) {
+	settings := DefaultUserSettings()
+	var muted string
+	err := database.QueryRow("SELECT display_name, bio, timezone, theme, posts_per_page, default_sort, muted_notifications FROM user_settings WHERE username = ?", username).
+		Scan(&settings.DisplayName, &settings.Bio, &settings.Timezone, &settings.Theme, &settings.PostsPerPage, &settings.DefaultSort, &muted)
+	if err == sql.ErrNoRows {
+		return DefaultUserSettings(), nil
+	}
+	if muted != "" {
+		settings.MutedNotifications = strings.Split(muted, ",")
+	}
+	return settings, err
+}
+
+// SetUserSettings saves the settings of a user
+func SetUserSettings(database *sql.DB, username string, settings UserSettings) error {
+	_, err := database.Exec(`INSERT INTO user_settings (username, display_name, bio, timezone, theme, posts_per_page, default_sort, muted_notifications) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (username) DO UPDATE SET display_name = excluded.display_name, bio = excluded.bio, timezone = excluded.timezone, theme = excluded.theme,
+		posts_per_page = excluded.posts_per_page, default_sort = excluded.default_sort, muted_notifications = excluded.muted_notifications`,
+		username, settings.DisplayName, settings.Bio, settings.Timezone, settings.Theme, settings.PostsPerPage, settings.DefaultSort, strings.Join(settings.MutedNotifications, ","))
+	return err
+}
+
+// GetDisplayNames returns the display names of the users who chose one among usernames, by username
+func GetDisplayNames(database *sql.DB, usernames []string) (map[string]string, error) {
+	names := map[string]string{}
+	if len(usernames) == 0 {
+		return names, nil
+	}
+	args := make([]interface{}, len(usernames))
+	for i, username := range usernames {
+		args[i] = username
+	}
+	rows, err := database.Query("SELECT username, display_name FROM user_settings WHERE display_name != '' AND username IN (?"+strings.Repeat(", ?", len(usernames)-1)+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var username, name string
+		if err := rows.Scan(&username, &name); err != nil {
+			return nil, err
+		}
+		names[username] = name
+	}
+	return names, rows.Err()
+}
+
+// SetEmail changes the email of a user, ErrNotFound if the user doesn't exist and ErrConflict if another user has the
+// email
+func SetEmail(database *sql.DB, username string, email string) error {
+	if _, err := GetUserEmail(database, username); err != nil {
+		return err
+	}
+	result, err := database.Exec("UPDATE users SET email = ? WHERE username = ? AND NOT EXISTS (SELECT 1 FROM users WHERE email = ? AND username != ?)", email, username, email, username)
+	// the statement changes nothing when the email is taken
+	if err := execError(result, err); err != ErrNotFound {
+		return err
+	}
+	return ErrConflict
+}
@@ -0,0 +1,220 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Store stores the users and their sessions, the posts with their comments and votes, and the categories. Its methods
+// work like the functions of the same name, and return the same errors.
+type Store interface {
+	// CreateTables creates the missing tables of the store and the default categories
+	CreateTables() error
+
+	AddUser(username string, email string, password string, cookie string, expires string) error
+	EmailNotTaken(email string) (bool, error)
+	UsernameNotTaken(username string) (bool, error)
+	GetUserInfo(submittedEmail string) (string, string, string, error)
+	GetUserEmail(username string) (string, error)
+	IsAdmin(username string) (bool, error)
+
+	GetUser(cookie string) (string, error)
+	CheckCookie(cookie string) (bool, error)
+	GetExpires(cookie string) (string, error)
+	UpdateCookie(token string, expiration time.Time, email string) error
+	Logout(username string) error
+
+	CreatePost(username string, title string, categories string, content string, attachments []Attachment, createdAt time.Time) (int, error)
+	GetPost(id string) (Post, error)
+	GetPostsByCategory(category string) ([]Post, error)
+	GetPostsByCategories() ([][]Post, error)
+	GetPostsByUser(username string) ([]Post, error)
+	GetLikedPosts(username string) ([]Post, error)
+	GetLatestPosts(category string, username string, limit int) ([]Post, error)
+	GetTopPostsInCategories(categories []string, since time.Time, limit int) ([]Post, error)
+
+	AddComment(username string, postId int, parentId int, content string, createdAt time.Time) (int, error)
+	GetComment(id int) (Comment, error)
+	GetComments(id string) ([]Comment, error)
+
+	HasUpvoted(username string, postId int) (bool, error)
+	HasDownvoted(username string, postId int) (bool, error)
+	AddVote(postId int, username string, vote int) error
+	UpdateVote(postId int, username string, vote int) error
+	RemoveVote(postId int, username string) error
+	IncreaseUpvotes(postId int) error
+	IncreaseDownvotes(postId int) error
+	DecreaseUpvotes(postId int) error
+	DecreaseDownvotes(postId int) error
+
+	GetCategories() ([]string, error)
+	GetCategoriesIcons() ([]string, error)
+	GetCategoryIcon(category string) (string, error)
+}
+
+// SQLStore is the store of a SQLite or a PostgreSQL database, through the functions of the package, which run the same
+// SQL on both databases
+type SQLStore struct {
+	DB *sql.DB
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// NewSQLStore returns the store of a SQLite or a PostgreSQL database, opened with ObservedDriver or
+// ObservedPostgresDriver
+func NewSQLStore(database *sql.DB) *SQLStore {
+	return &SQLStore{DB: database}
+}
+
+func (store *SQLStore) CreateTables() error {
+	for _, create := range []func(*sql.DB) error{
+		CreateUsersTable,
+		CreatePostTable,
+		CreateCommentTable,
+		CreateVoteTable,
+		CreateCategoriesTable,
+		CreateCategories,
+		CreateCategoriesIcons,
+	} {
+		if err := create(store.DB); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *SQLStore) AddUser(username string, email string, password string, cookie string, expires string) error {
+	return AddUser(store.DB, username, email, password, cookie, expires)
+}
+
+func (store *SQLStore) EmailNotTaken(email string) (bool, error) {
+	return EmailNotTaken(store.DB, email)
+}
+
+func (store *SQLStore) UsernameNotTaken(username string) (bool, error) {
+	return UsernameNotTaken(store.DB, username)
+}
+
+func (store *SQLStore) GetUserInfo(submittedEmail string) (string, string, string, error) {
+	return GetUserInfo(store.DB, submittedEmail)
+}
+
+func (store *SQLStore) GetUserEmail(username string) (string, error) {
+	return GetUserEmail(store.DB, username)
+}
+
+func (store *SQLStore) IsAdmin(username string) (bool, error) {
+	return IsAdmin(store.DB, username)
+}
+
+func (store *SQLStore) GetUser(cookie string) (string, error) {
+	return GetUser(store.DB, cookie)
+}
+
+func (store *SQLStore) CheckCookie(cookie string) (bool, error) {
+	return CheckCookie(store.DB, cookie)
+}
+
+func (store *SQLStore) GetExpires(cookie string) (string, error) {
+	return GetExpires(store.DB, cookie)
+}
+
+func (store *SQLStore) UpdateCookie(token string, expiration time.Time, email string) error {
+	return UpdateCookie(store.DB, token, expiration, email)
+}
+
+func (store *SQLStore) Logout(username string) error {
+	return Logout(store.DB, username)
+}
+
+func (store *SQLStore) CreatePost(username string, title string, categories string, content string, attachments []Attachment, createdAt time.Time) (int, error) {
+	return CreatePost(store.DB, username, title, categories, content, attachments, createdAt)
+}
+
+func (store *SQLStore) GetPost(id string) (Post, error) {
+	return GetPost(store.DB, id)
+}
+
+func (store *SQLStore) GetPostsByCategory(category string) ([]Post, error) {
+	return GetPostsByCategory(store.DB, category)
+}
+
+func (store *SQLStore) GetPostsByCategories() ([][]Post, error) {
+	return GetPostsByCategories(store.DB)
+}
+
+func (store *SQLStore) GetPostsByUser(username string) ([]Post, error) {
+	return GetPostsByUser(store.DB, username)
+}
+
+func (store *SQLStore) GetLikedPosts(username string) ([]Post, error) {
+	return GetLikedPosts(store.DB, username)
+}
+
+func (store *SQLStore) GetLatestPosts(category string, username string, limit int) ([]Post, error) {
+	return GetLatestPosts(store.DB, category, username, limit)
+}
+
+func (store *SQLStore) GetTopPostsInCategories(categories []string, since time.Time, limit int) ([]Post, error) {
+	return GetTopPostsInCategories(store.DB, categories, since, limit)
+}
+
+func (store *SQLStore) AddComment(username string, postId int, parentId int, content string, createdAt time.Time) (int, error) {
+	return AddComment(store.DB, username, postId, parentId, content, createdAt)
+}
+
+func (store *SQLStore) GetComment(id int) (Comment, error) {
+	return GetComment(store.DB, id)
+}
+
+func (store *SQLStore) GetComments(id string) ([]Comment, error) {
+	return GetComments(store.DB, id)
+}
+
+func (store *SQLStore) HasUpvoted(username string, postId int) (bool, error) {
+	return HasUpvoted(store.DB, username, postId)
+}
+
+func (store *SQLStore) HasDownvoted(username string, postId int) (bool, error) {
+	return HasDownvoted(store.DB, username, postId)
+}
+
+func (store *SQLStore) AddVote(postId int, username string, vote int) error {
+	return AddVote(store.DB, postId, username, vote)
+}
+
+func (store *SQLStore) UpdateVote(postId int, username string, vote int) error {
+	return UpdateVote(store.DB, postId, username, vote)
+}
+
+func (store *SQLStore) RemoveVote(postId int, username string) error {
+	return RemoveVote(store.DB, postId, username)
+}
+
+func (store *SQLStore) IncreaseUpvotes(postId int) error {
+	return IncreaseUpvotes(store.DB, postId)
+}
+
+func (store *SQLStore) IncreaseDownvotes(postId int) error {
+	return IncreaseDownvotes(store.DB, postId)
+}
+
+func (store *SQLStore) DecreaseUpvotes(postId int) error {
+	return DecreaseUpvotes(store.DB, postId)
+}
+
+func (store *SQLStore) DecreaseDownvotes(postId int) error {
+	return DecreaseDownvotes(store.DB, postId)
+}
+
+func (store *SQLStore) GetCategories() ([]string, error) {
+	return GetCategories(store.DB)
+}
+
+func (store *SQLStore) GetCategoriesIcons() ([]string, error) {
+	return GetCategoriesIcons(store.DB)
+}
+
+func (store *SQLStore) GetCategoryIcon(category string) (string, error) {
+	return GetCategoryIcon(store.DB, category)
+}
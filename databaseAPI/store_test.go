@@ -0,0 +1,603 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// forumTables are the tables dropped from the PostgreSQL database of the tests before they run
+const forumTables = "users, posts, comments, votes, categories, attachments, notifications, subscriptions, email_preferences, " +
+	"user_settings, bookmarks, reports, webhooks, webhook_deliveries, imports, schema_version"
+
+// storeChecks are the checks run in order on every store, each one relying on the rows added by the previous ones
+var storeChecks = []struct {
+	name  string
+	check func(Store) error
+}{
+	{"categories", checkCategories},
+	{"users", checkUsers},
+	{"sessions", checkSessions},
+	{"posts", checkPosts},
+	{"comments", checkComments},
+	{"votes", checkVotes},
+}
+
+// openSQLite returns the store of a new SQLite database
+func openSQLite(t *testing.T) (*sql.DB, Store) {
+	database, err := sql.Open(ObservedDriver, filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database, NewSQLStore(database)
+}
+
+// openPostgres returns the store of the PostgreSQL database of FORUM_TEST_POSTGRES, without the tables of the forum,
+// and skips the test when it isn't set
+func openPostgres(t *testing.T) (*sql.DB, Store) {
+	url := os.Getenv("FORUM_TEST_POSTGRES")
+	if url == "" {
+		t.Skip("FORUM_TEST_POSTGRES is not set")
+	}
+	database, err := sql.Open(ObservedPostgresDriver, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec("DROP TABLE IF EXISTS " + forumTables); err != nil {
+		database.Close()
+		t.Fatal(err)
+	}
+	return database, NewSQLStore(database)
+}
+
+func TestStores(t *testing.T) {
+	// bcrypt with the forum's cost is slow
+	previousCost := bcryptCost
+	SetBcryptCost(bcrypt.MinCost)
+	defer SetBcryptCost(previousCost)
+
+	stores := []struct {
+		name string
+		open func(*testing.T) (*sql.DB, Store)
+	}{
+		{"SQLite", openSQLite},
+		{"PostgreSQL", openPostgres},
+	}
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			database, store := s.open(t)
+			defer database.Close()
+			if err := store.CreateTables(); err != nil {
+				t.Fatal(err)
+			}
+			// creating the tables again must keep them
+			if err := store.CreateTables(); err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range storeChecks {
+				t.Run(c.name, func(t *testing.T) {
+					if err := c.check(store); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+			// the functions of the package run the same SQL on both databases
+			t.Run("functions", func(t *testing.T) {
+				if err := checkFunctions(database); err != nil {
+					t.Fatal(err)
+				}
+			})
+		})
+	}
+}
+
+// expect returns an error describing the difference if got isn't equal to want
+func expect(what string, got interface{}, want interface{}) error {
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("%s: got %#v, want %#v", what, got, want)
+	}
+	return nil
+}
+
+// expectError returns an error describing the difference if err isn't target, as tested by errors.Is
+func expectError(what string, err error, target error) error {
+	if !errors.Is(err, target) {
+		return fmt.Errorf("%s: got error %v, want %v", what, err, target)
+	}
+	return nil
+}
+
+// firstError returns the first error that isn't nil
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkCategories(store Store) error {
+	var names []string
+	var icons []string
+	for _, category := range defaultCategories {
+		names = append(names, category[0])
+		icons = append(icons, category[1])
+	}
+	categories, err := store.GetCategories()
+	if err != nil {
+		return err
+	}
+	categoriesIcons, err := store.GetCategoriesIcons()
+	if err != nil {
+		return err
+	}
+	icon, err := store.GetCategoryIcon("Science")
+	if err != nil {
+		return err
+	}
+	_, missingErr := store.GetCategoryIcon("Missing")
+	return firstError(
+		expect("GetCategories", categories, names),
+		expect("GetCategoriesIcons", categoriesIcons, icons),
+		expect("GetCategoryIcon", icon, "fa-flask"),
+		expectError("GetCategoryIcon of a missing category", missingErr, ErrNotFound),
+	)
+}
+
+func checkUsers(store Store) error {
+	usernameFree, err := store.UsernameNotTaken("alice")
+	if err != nil {
+		return err
+	}
+	emailFree, err := store.EmailNotTaken("alice@example.com")
+	if err != nil {
+		return err
+	}
+	if err := firstError(
+		expect("UsernameNotTaken before AddUser", usernameFree, true),
+		expect("EmailNotTaken before AddUser", emailFree, true),
+	); err != nil {
+		return err
+	}
+	// bcrypt with the forum's cost is slow, the suite adds only two users
+	if err := store.AddUser("alice", "alice@example.com", "secret", "", ""); err != nil {
+		return err
+	}
+	if err := store.AddUser("bob", "bob@example.com", "secret", "", ""); err != nil {
+		return err
+	}
+	username, email, password, err := store.GetUserInfo("alice@example.com")
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(password), []byte("secret")); err != nil {
+		return errors.New("GetUserInfo: the password isn't hashed with bcrypt")
+	}
+	aliceEmail, err := store.GetUserEmail("alice")
+	if err != nil {
+		return err
+	}
+	aliceAdmin, err := store.IsAdmin("alice")
+	if err != nil {
+		return err
+	}
+	bobAdmin, err := store.IsAdmin("bob")
+	if err != nil {
+		return err
+	}
+	missingAdmin, err := store.IsAdmin("missing")
+	if err != nil {
+		return err
+	}
+	usernameFree, err = store.UsernameNotTaken("alice")
+	if err != nil {
+		return err
+	}
+	emailFree, err = store.EmailNotTaken("alice@example.com")
+	if err != nil {
+		return err
+	}
+	_, _, _, missingErr := store.GetUserInfo("missing@example.com")
+	_, missingEmailErr := store.GetUserEmail("missing")
+	return firstError(
+		expect("UsernameNotTaken after AddUser", usernameFree, false),
+		expect("EmailNotTaken after AddUser", emailFree, false),
+		expectError("AddUser with a taken username", store.AddUser("alice", "other@example.com", "secret", "", ""), ErrConflict),
+		expectError("AddUser with a taken email", store.AddUser("other", "alice@example.com", "secret", "", ""), ErrConflict),
+		expect("GetUserInfo", []string{username, email}, []string{"alice", "alice@example.com"}),
+		expectError("GetUserInfo of a missing user", missingErr, ErrNotFound),
+		expect("GetUserEmail", aliceEmail, "alice@example.com"),
+		expectError("GetUserEmail of a missing user", missingEmailErr, ErrNotFound),
+		expect("IsAdmin of the first user", aliceAdmin, false),
+		expect("IsAdmin of the second user", bobAdmin, false),
+		expect("IsAdmin of a missing user", missingAdmin, false),
+	)
+}
+
+func checkSessions(store Store) error {
+	expiration := time.Date(2030, 1, 2, 3, 4, 5, 0, time.Local)
+	if err := store.UpdateCookie("token", expiration, "alice@example.com"); err != nil {
+		return err
+	}
+	username, err := store.GetUser("token")
+	if err != nil {
+		return err
+	}
+	valid, err := store.CheckCookie("token")
+	if err != nil {
+		return err
+	}
+	expires, err := store.GetExpires("token")
+	if err != nil {
+		return err
+	}
+	missingValid, err := store.CheckCookie("missing")
+	if err != nil {
+		return err
+	}
+	_, missingErr := store.GetUser("missing")
+	if err := firstError(
+		expect("GetUser", username, "alice"),
+		expect("CheckCookie", valid, true),
+		expect("GetExpires", expires, FormatTime(expiration)),
+		expectError("GetUser of a missing cookie", missingErr, ErrNotFound),
+		expect("CheckCookie of a missing cookie", missingValid, false),
+		expectError("UpdateCookie of a missing user", store.UpdateCookie("other", expiration, "missing@example.com"), ErrNotFound),
+	); err != nil {
+		return err
+	}
+	if err := store.Logout("alice"); err != nil {
+		return err
+	}
+	valid, err = store.CheckCookie("token")
+	if err != nil {
+		return err
+	}
+	_, loggedOutErr := store.GetUser("token")
+	return firstError(
+		expectError("GetUser after Logout", loggedOutErr, ErrNotFound),
+		expect("CheckCookie after Logout", valid, false),
+	)
+}
+
+// createPost creates a post in the General category
+func createPost(store Store, username string, title string, createdAt time.Time) (int, error) {
+	return store.CreatePost(username, title, "General", "Content", nil, createdAt)
+}
+
+func checkPosts(store Store) error {
+	first, err := store.CreatePost("alice", "First", "Science,Music", "Content", nil, time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local))
+	if err != nil {
+		return err
+	}
+	second, err := store.CreatePost("bob", "Second", "Music", "More", nil, time.Date(2020, 1, 3, 3, 4, 5, 0, time.Local))
+	if err != nil {
+		return err
+	}
+	third, err := store.CreatePost("alice", "Third", "Art", "Again", nil, time.Date(2020, 1, 4, 3, 4, 5, 0, time.Local))
+	if err != nil {
+		return err
+	}
+	if first == 0 || second == 0 || third == 0 || first == second || second == third {
+		return fmt.Errorf("CreatePost: got ids %d, %d and %d", first, second, third)
+	}
+	want := Post{Id: first, Username: "alice", Title: "First", Categories: []string{"Science", "Music"}, Content: "Content", CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local).UTC()}
+	post, err := store.GetPost(strconv.Itoa(first))
+	if err != nil {
+		return err
+	}
+	_, missingErr := store.GetPost("0")
+	inMusic, err := store.GetPostsByCategory("Music")
+	if err != nil {
+		return err
+	}
+	byCategories, err := store.GetPostsByCategories()
+	if err != nil {
+		return err
+	}
+	byAlice, err := store.GetPostsByUser("alice")
+	if err != nil {
+		return err
+	}
+	latest, err := store.GetLatestPosts("", "", 2)
+	if err != nil {
+		return err
+	}
+	inCategory, err := store.GetLatestPosts("Music", "", 10)
+	if err != nil {
+		return err
+	}
+	byUser, err := store.GetLatestPosts("", "alice", 10)
+	if err != nil {
+		return err
+	}
+	top, err := store.GetTopPostsInCategories([]string{"Music"}, time.Date(2020, 1, 2, 12, 0, 0, 0, time.Local), 10)
+	if err != nil {
+		return err
+	}
+	return firstError(
+		expect("GetPost", post, want),
+		expectError("GetPost of a missing post", missingErr, ErrNotFound),
+		expect("GetPostsByCategory", postIds(inMusic), []int{first, second}),
+		expect("GetPostsByCategories", len(byCategories), len(defaultCategories)),
+		expect("GetPostsByUser", postIds(byAlice), []int{first, third}),
+		expect("GetLatestPosts", postIds(latest), []int{third, second}),
+		expect("GetLatestPosts in a category", postIds(inCategory), []int{second, first}),
+		expect("GetLatestPosts by a user", postIds(byUser), []int{third, first}),
+		expect("GetTopPostsInCategories", postIds(top), []int{second}),
+	)
+}
+
+func checkComments(store Store) error {
+	post, err := createPost(store, "alice", "Commented", time.Date(2020, 2, 1, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		return err
+	}
+	first, err := store.AddComment("bob", post, 0, "Hello", time.Date(2020, 2, 1, 1, 0, 0, 0, time.Local))
+	if err != nil {
+		return err
+	}
+	reply, err := store.AddComment("alice", post, first, "Hi", time.Date(2020, 2, 1, 2, 0, 0, 0, time.Local))
+	if err != nil {
+		return err
+	}
+	if first == 0 || reply == 0 || first == reply {
+		return fmt.Errorf("AddComment: got ids %d and %d", first, reply)
+	}
+	comment, err := store.GetComment(reply)
+	if err != nil {
+		return err
+	}
+	_, missingErr := store.GetComment(0)
+	comments, err := store.GetComments(strconv.Itoa(post))
+	if err != nil {
+		return err
+	}
+	return firstError(
+		expect("GetComment", comment, Comment{Id: reply, PostId: post, ParentId: first, Username: "alice", Content: "Hi", CreatedAt: time.Date(2020, 2, 1, 2, 0, 0, 0, time.Local).UTC()}),
+		expectError("GetComment of a missing comment", missingErr, ErrNotFound),
+		expect("GetComments", comments, []Comment{
+			{Id: first, Username: "bob", Content: "Hello", CreatedAt: time.Date(2020, 2, 1, 1, 0, 0, 0, time.Local).UTC()},
+			{Id: reply, ParentId: first, Username: "alice", Content: "Hi", CreatedAt: time.Date(2020, 2, 1, 2, 0, 0, 0, time.Local).UTC()},
+		}),
+	)
+}
+
+// voteState returns whether a user has upvoted and downvoted a post, the votes counts of the post and the posts the
+// user likes
+func voteState(store Store, username string, postId int) (upvoted bool, downvoted bool, votes []int, liked []int, err error) {
+	if upvoted, err = store.HasUpvoted(username, postId); err != nil {
+		return
+	}
+	if downvoted, err = store.HasDownvoted(username, postId); err != nil {
+		return
+	}
+	post, err := store.GetPost(strconv.Itoa(postId))
+	if err != nil {
+		return
+	}
+	likedPosts, err := store.GetLikedPosts(username)
+	return upvoted, downvoted, []int{post.UpVotes, post.DownVotes}, postIds(likedPosts), err
+}
+
+func checkVotes(store Store) error {
+	post, err := createPost(store, "alice", "Voted", time.Date(2020, 3, 1, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		return err
+	}
+	if err := firstError(store.AddVote(post, "bob", 1), store.IncreaseUpvotes(post)); err != nil {
+		return err
+	}
+	upvoted, downvoted, votes, liked, err := voteState(store, "bob", post)
+	if err != nil {
+		return err
+	}
+	if err := firstError(
+		expect("HasUpvoted", upvoted, true),
+		expect("HasDownvoted", downvoted, false),
+		expect("votes after IncreaseUpvotes", votes, []int{1, 0}),
+		expect("GetLikedPosts", liked, []int{post}),
+	); err != nil {
+		return err
+	}
+	if err := firstError(store.UpdateVote(post, "bob", -1), store.DecreaseUpvotes(post), store.IncreaseDownvotes(post)); err != nil {
+		return err
+	}
+	upvoted, downvoted, votes, liked, err = voteState(store, "bob", post)
+	if err != nil {
+		return err
+	}
+	if err := firstError(
+		expect("HasUpvoted after UpdateVote", upvoted, false),
+		expect("HasDownvoted after UpdateVote", downvoted, true),
+		expect("votes after UpdateVote", votes, []int{0, 1}),
+		expect("GetLikedPosts after UpdateVote", liked, []int{}),
+	); err != nil {
+		return err
+	}
+	if err := firstError(store.RemoveVote(post, "bob"), store.DecreaseDownvotes(post)); err != nil {
+		return err
+	}
+	_, downvoted, votes, _, err = voteState(store, "bob", post)
+	if err != nil {
+		return err
+	}
+	return firstError(
+		expect("HasDownvoted after RemoveVote", downvoted, false),
+		expect("downvotes after DecreaseDownvotes", votes, []int{0, 0}),
+		expectError("RemoveVote of a missing vote", store.RemoveVote(post, "bob"), ErrNotFound),
+		expectError("IncreaseUpvotes of a missing post", store.IncreaseUpvotes(0), ErrNotFound),
+	)
+}
+
+// postIds returns the ids of posts
+func postIds(posts []Post) []int {
+	ids := []int{}
+	for _, post := range posts {
+		ids = append(ids, post.Id)
+	}
+	return ids
+}
+
+// checkFunctions checks the functions of the package whose SQL differs the most between the databases, on the rows
+// added by the store checks
+func checkFunctions(database *sql.DB) error {
+	if err := firstError(CreateTables(database), CreateTables(database), Migrate(database)); err != nil {
+		return err
+	}
+	version, err := GetSchemaVersion(database)
+	if err != nil {
+		return err
+	}
+	if err := expect("GetSchemaVersion after Migrate", version, SchemaVersion); err != nil {
+		return err
+	}
+
+	settings := DefaultUserSettings()
+	settings.DisplayName = "Alice"
+	if err := SetUserSettings(database, "alice", settings); err != nil {
+		return err
+	}
+	settings.DisplayName = "Alice B."
+	if err := SetUserSettings(database, "alice", settings); err != nil {
+		return err
+	}
+	saved, err := GetUserSettings(database, "alice")
+	if err != nil {
+		return err
+	}
+	preferences, err := GetEmailPreferences(database, "alice")
+	if err != nil {
+		return err
+	}
+	if err := SetEmailPreferences(database, "alice", false, true); err != nil {
+		return err
+	}
+	changed, err := GetEmailPreferences(database, "alice")
+	if err != nil {
+		return err
+	}
+	notification, err := AddNotification(database, Notification{Username: "alice", Kind: "reply", Actor: "bob", Message: "Hi"}, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := firstError(AddUser(database, "carol", "carol@example.com", "secret", "", ""), SetRole(database, "carol", RoleBanned)); err != nil {
+		return err
+	}
+	promoteError := SetAdmin(database, "carol", true)
+	carolBanned, err := IsBanned(database, "carol")
+	if err != nil {
+		return err
+	}
+	if err := firstError(
+		expect("GetUserSettings after SetUserSettings twice", saved.DisplayName, "Alice B."),
+		expect("GetEmailPreferences after SetEmailPreferences", []interface{}{changed.Digest, changed.Replies, changed.UnsubscribeToken}, []interface{}{false, true, preferences.UnsubscribeToken}),
+		expect("AddNotification returns an id", notification != 0, true),
+		expectError("SetAdmin of a banned user", promoteError, ErrBanned),
+		expect("IsBanned after SetAdmin", carolBanned, true),
+		expectError("SetAdmin of a missing user", SetAdmin(database, "nobody", true), ErrNotFound),
+	); err != nil {
+		return err
+	}
+
+	inArt, err := GetPostsByCategory(database, "Art")
+	if err != nil {
+		return err
+	}
+	if err := firstError(
+		Follow(database, "bob", "category", "Art", time.Now()),
+		Follow(database, "bob", "category", "Art", time.Now()),
+		Follow(database, "bob", "category", "Music", time.Now()),
+	); err != nil {
+		return err
+	}
+	feed, err := GetFeedPosts(database, "bob", 1)
+	if err != nil {
+		return err
+	}
+	if err := RemoveCategory(database, "Art", "Music"); err != nil {
+		return err
+	}
+	followed, err := GetFollowed(database, "bob", "category")
+	if err != nil {
+		return err
+	}
+	if err := firstError(
+		expect("GetFeedPosts", postIds(feed), postIds(inArt)),
+		expect("GetFollowed after RemoveCategory", followed, []string{"Music"}),
+	); err != nil {
+		return err
+	}
+
+	// the wildcards of LIKE in the names of the categories match only themselves
+	if _, err := database.Exec("INSERT INTO posts (username, title, categories, content, created_at, upvotes, downvotes) VALUES ('alice', 'Wildcards', 'abc,x%y', '', ?, 0, 0)", FormatTime(time.Now())); err != nil {
+		return err
+	}
+	var counts []int
+	for _, category := range []string{"abc", "a_c", "x%y", "x%", "x\\%y"} {
+		count, err := CountPostsInCategory(database, category)
+		if err != nil {
+			return err
+		}
+		counts = append(counts, count)
+	}
+	latest, err := GetLatestPosts(database, "a_c", "", 10)
+	if err != nil {
+		return err
+	}
+	if err := firstError(
+		expect("CountPostsInCategory with wildcards", counts, []int{1, 0, 1, 0, 0}),
+		expect("GetLatestPosts with wildcards", len(latest), 0),
+	); err != nil {
+		return err
+	}
+
+	if _, err := database.Exec("UPDATE posts SET upvotes = 5 WHERE id = ?", inArt[0].Id); err != nil {
+		return err
+	}
+	recounted, err := RecountVotes(database)
+	if err != nil {
+		return err
+	}
+	webhook, err := AddWebhook(database, Webhook{URL: "http://localhost/", Events: []string{"vote.created"}, Active: true}, time.Now())
+	if err != nil {
+		return err
+	}
+	var bobPost int
+	if err := database.QueryRow("SELECT id FROM posts WHERE username = 'bob'").Scan(&bobPost); err != nil {
+		return err
+	}
+	if _, err := AddWebhookDelivery(database, webhook, "vote.created", `{"data":{"username":"bob"}}`, "bob", 0, time.Now()); err != nil {
+		return err
+	}
+	if _, err := AddWebhookDelivery(database, webhook, "vote.created", `{"data":{"username":"alice"}}`, "alice", bobPost, time.Now()); err != nil {
+		return err
+	}
+	if _, err := AddWebhookDelivery(database, webhook, "vote.created", `{"data":{"username":"alice"}}`, "alice", 0, time.Now()); err != nil {
+		return err
+	}
+	if _, err := DeleteUser(database, "bob", true); err != nil {
+		return err
+	}
+	deliveries, err := GetWebhookDeliveries(database, webhook, "", 10)
+	if err != nil {
+		return err
+	}
+	var payloads []string
+	for _, delivery := range deliveries {
+		payloads = append(payloads, delivery.Payload)
+	}
+	return firstError(
+		expect("RecountVotes", recounted, 1),
+		expect("deliveries after DeleteUser", payloads, []string{`{"data":{"username":"alice"}}`}),
+	)
+}
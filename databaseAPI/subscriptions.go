@@ -0,0 +1,119 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"time"
+)
+
+// Follow subscribes a user to a target, kind tells what the target is
+func Follow(database *sql.DB, username string, kind string, target string, createdAt time.Time) error {
+	createdAtString := FormatTime(createdAt)
+	_, err := database.Exec("INSERT INTO subscriptions (username, kind, target, created_at) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING", username, kind, target, createdAtString)
+	return err
+}
+
+// Unfollow unsubscribes a user from a target
+func Unfollow(database *sql.DB, username string, kind string, target string) error {
+	_, err := database.Exec("DELETE FROM subscriptions WHERE username = ? AND kind = ? AND target = ?", username, kind, target)
+	return err
+}
+
+// IsFollowing returns true if the user follows the target
+func IsFollowing(database *sql.DB, username string, kind string, target string) (bool, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM subscriptions WHERE username = ? AND kind = ? AND target = ?", username, kind, target).Scan(&count)
+	return count > 0, err
+}
+
+// GetFollowed returns the targets of a kind followed by a user
+func GetFollowed(database *sql.DB, username string, kind string) ([]string, error) {
+	rows, err := database.Query("SELECT target FROM subscriptions WHERE username = ? AND kind = ? ORDER BY target", username, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var targets []string
+	for rows.Next() {
+		var target string
+		if err := rows.Scan(&target); err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+// GetFollowers returns the users following a target
+func GetFollowers(database *sql.DB, kind string, target string) ([]string, error) {
+	rows, err := database.Query("SELECT username FROM subscriptions WHERE kind = ? AND target = ? ORDER BY username", kind, target)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var usernames []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	return usernames, rows.Err()
+}
+
+// GetSubscribers returns the users following at least one target of a kind
+func GetSubscribers(database *sql.DB, kind string) ([]string, error) {
+	rows, err := database.Query("SELECT DISTINCT username FROM subscriptions WHERE kind = ? ORDER BY username", kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var usernames []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	return usernames, rows.Err()
+}
+
+// feedItems selects the posts and comments from what a user follows: posts in followed categories, posts and comments
+// of followed users and comments in followed threads. The user's own posts and comments are left out. It takes the
+// username six times.
+var feedItems = `SELECT 'post' AS kind, p.id AS post_id, p.title, 0 AS comment_id, p.username, p.content, p.created_at FROM posts p
+	WHERE p.username != ? AND (
+		p.username IN (SELECT target FROM subscriptions WHERE username = ? AND kind = 'user')
+		OR EXISTS (SELECT 1 FROM subscriptions s WHERE s.username = ? AND s.kind = 'category' AND ` + categoryIn("p.categories", "s.target") + `))
+	UNION ALL
+	SELECT 'comment', c.post_id, p.title, c.id, c.username, c.content, c.created_at FROM comments c JOIN posts p ON p.id = c.post_id
+	WHERE c.username != ? AND (
+		c.username IN (SELECT target FROM subscriptions WHERE username = ? AND kind = 'user')
+		OR CAST(c.post_id AS TEXT) IN (SELECT target FROM subscriptions WHERE username = ? AND kind = 'thread'))`
+
+// GetFeed returns the latest posts and comments from what a user follows, newest first
+func GetFeed(database *sql.DB, username string, limit int) ([]FeedItem, error) {
+	rows, err := database.Query(feedItems+" ORDER BY 7 DESC, 2 DESC, 4 DESC LIMIT ?", username, username, username, username, username, username, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedItem
+	for rows.Next() {
+		var item FeedItem
+		if err := rows.Scan(&item.Kind, &item.PostId, &item.PostTitle, &item.CommentId, &item.Username, &item.Content, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetFeedPosts returns the posts of the feed of a user, ordered by their latest activity in the feed
+func GetFeedPosts(database *sql.DB, username string, limit int) ([]Post, error) {
+	return scanPosts(database.Query(`SELECT p.id, p.username, p.title, p.categories, p.content, p.created_at, p.upvotes, p.downvotes
+		FROM posts p JOIN (SELECT post_id, MAX(created_at) AS activity FROM (`+feedItems+`) items GROUP BY post_id) f ON f.post_id = p.id
+		ORDER BY f.activity DESC, p.id DESC LIMIT ?`, username, username, username, username, username, username, limit))
+}
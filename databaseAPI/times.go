@@ -0,0 +1,48 @@
+package databaseAPI
+
+import (
+	"errors"
+	"time"
+)
+
+// timeLayout is the layout of the times of the database, RFC 3339 in UTC so that they sort in chronological order
+const timeLayout = "2006-01-02T15:04:05Z"
+
+// legacyTimeLayout is the layout of the times saved in the local time of the server before the schema version 2
+const legacyTimeLayout = "2006-01-02 15:04:05"
+
+// FormatTime returns a time as it is saved in the database
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// ParseTime returns a time saved in the database, in UTC
+func ParseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	return t.UTC(), err
+}
+
+// timeScanner scans a time saved in the database into a time.Time
+type timeScanner struct {
+	t *time.Time
+}
+
+// scanTime returns the destination of Scan for a column saving a time, which is parsed into t
+func scanTime(t *time.Time) timeScanner {
+	return timeScanner{t: t}
+}
+
+func (scanner timeScanner) Scan(value interface{}) error {
+	var err error
+	switch value := value.(type) {
+	case string:
+		*scanner.t, err = ParseTime(value)
+	case []byte:
+		*scanner.t, err = ParseTime(string(value))
+	case time.Time:
+		*scanner.t = value.UTC()
+	default:
+		err = errors.New("unsupported time value")
+	}
+	return err
+}
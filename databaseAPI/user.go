@@ -10,24 +10,35 @@ type User struct {
 	username   string
 }
 
-// GetUser get user by cookie
-func GetUser(database *sql.DB, cookie string) string {
-	rows, _ := database.Query("SELECT username FROM users WHERE cookie = ?", cookie)
+// GetUser get user by cookie, ErrNotFound if no user has it
+func GetUser(database *sql.DB, cookie string) (string, error) {
 	var username string
-	for rows.Next() {
-		rows.Scan(&username)
-	}
-	return username
+	err := database.QueryRow("SELECT username FROM users WHERE cookie = ?", cookie).Scan(&username)
+	return username, dbError(err)
 }
 
-// GetUserInfo returns the username, email and hashed password of a user
-func GetUserInfo(database *sql.DB, submittedEmail string) (string, string, string) {
+// GetUserInfo returns the username, email and hashed password of a user, ErrNotFound if no user has the email
+func GetUserInfo(database *sql.DB, submittedEmail string) (string, string, string, error) {
 	var user string
 	var email string
 	var password string
-	rows, _ := database.Query("SELECT username, email, password FROM users WHERE email = ?", submittedEmail)
-	for rows.Next() {
-		rows.Scan(&user, &email, &password)
+	err := database.QueryRow("SELECT username, email, password FROM users WHERE email = ?", submittedEmail).Scan(&user, &email, &password)
+	return user, email, password, dbError(err)
+}
+
+// GetUserEmail returns the email address of a user, ErrNotFound if the user doesn't exist
+func GetUserEmail(database *sql.DB, username string) (string, error) {
+	var email string
+	err := database.QueryRow("SELECT email FROM users WHERE username = ?", username).Scan(&email)
+	return email, dbError(err)
+}
+
+// IsAdmin returns true if the user is an admin of the forum
+func IsAdmin(database *sql.DB, username string) (bool, error) {
+	var role string
+	err := database.QueryRow("SELECT role FROM users WHERE username = ?", username).Scan(&role)
+	if err == sql.ErrNoRows {
+		return false, nil
 	}
-	return user, email, password
+	return role == "admin", err
 }
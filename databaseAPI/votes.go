@@ -6,69 +6,54 @@ import (
 )
 
 // HasUpvoted check if user has upvoted a post
-func HasUpvoted(database *sql.DB, username string, postId int) bool {
-	rows, _ := database.Query("SELECT vote FROM votes WHERE username = ? AND post_id = ? AND vote = 1", username, postId)
-	vote := 0
-	for rows.Next() {
-		rows.Scan(&vote)
-	}
-	if vote != 0 {
-		return true
-	}
-	return false
+func HasUpvoted(database *sql.DB, username string, postId int) (bool, error) {
+	return hasVoted(database, username, postId, 1)
 }
 
 // HasDownvoted check if user has downvoted a post
-func HasDownvoted(database *sql.DB, username string, postId int) bool {
-	rows, _ := database.Query("SELECT vote FROM votes WHERE username = ? AND post_id = ? AND vote = -1", username, postId)
-	vote := 0
-	for rows.Next() {
-		rows.Scan(&vote)
-	}
-	if vote != 0 {
-		return true
-	}
-	return false
+func HasDownvoted(database *sql.DB, username string, postId int) (bool, error) {
+	return hasVoted(database, username, postId, -1)
 }
 
-// RemoveVote removes a vote from a post
-func RemoveVote(database *sql.DB, postId int, username string) {
-	statement, _ := database.Prepare("DELETE FROM votes WHERE post_id = ? AND username = ?")
-	statement.Exec(postId, username)
+// hasVoted returns true if a user has given a vote to a post
+func hasVoted(database *sql.DB, username string, postId int, vote int) (bool, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM votes WHERE username = ? AND post_id = ? AND vote = ?", username, postId, vote).Scan(&count)
+	return count != 0, err
 }
 
-// DecreaseUpvotes decreases the upvotes of a post by 1
-func DecreaseUpvotes(database *sql.DB, postId int) {
-	statement, _ := database.Prepare("UPDATE posts SET upvotes = upvotes - 1 WHERE id = ?")
-	statement.Exec(postId)
+// RemoveVote removes a vote from a post, ErrNotFound if the user hasn't voted
+func RemoveVote(database *sql.DB, postId int, username string) error {
+	return execError(database.Exec("DELETE FROM votes WHERE post_id = ? AND username = ?", postId, username))
 }
 
-// DecreaseDownvotes decreases the downvotes of a post by 1
-func DecreaseDownvotes(database *sql.DB, postId int) {
-	statement, _ := database.Prepare("UPDATE posts SET downvotes = downvotes - 1 WHERE id = ?")
-	statement.Exec(postId)
+// DecreaseUpvotes decreases the upvotes of a post by 1, ErrNotFound if the post doesn't exist
+func DecreaseUpvotes(database *sql.DB, postId int) error {
+	return execError(database.Exec("UPDATE posts SET upvotes = upvotes - 1 WHERE id = ?", postId))
 }
 
-// IncreaseUpvotes increases the upvotes of a post by 1
-func IncreaseUpvotes(database *sql.DB, postId int) {
-	statement, _ := database.Prepare("UPDATE posts SET upvotes = upvotes + 1 WHERE id = ?")
-	statement.Exec(postId)
+// DecreaseDownvotes decreases the downvotes of a post by 1, ErrNotFound if the post doesn't exist
+func DecreaseDownvotes(database *sql.DB, postId int) error {
+	return execError(database.Exec("UPDATE posts SET downvotes = downvotes - 1 WHERE id = ?", postId))
 }
 
-// IncreaseDownvotes increases the downvotes of a post by 1
-func IncreaseDownvotes(database *sql.DB, postId int) {
-	statement, _ := database.Prepare("UPDATE posts SET downvotes = downvotes + 1 WHERE id = ?")
-	statement.Exec(postId)
+// IncreaseUpvotes increases the upvotes of a post by 1, ErrNotFound if the post doesn't exist
+func IncreaseUpvotes(database *sql.DB, postId int) error {
+	return execError(database.Exec("UPDATE posts SET upvotes = upvotes + 1 WHERE id = ?", postId))
+}
+
+// IncreaseDownvotes increases the downvotes of a post by 1, ErrNotFound if the post doesn't exist
+func IncreaseDownvotes(database *sql.DB, postId int) error {
+	return execError(database.Exec("UPDATE posts SET downvotes = downvotes + 1 WHERE id = ?", postId))
 }
 
 // AddVote adds a vote to the database
-func AddVote(database *sql.DB, postId int, username string, vote int) {
-	statement, _ := database.Prepare("INSERT INTO votes (username, post_id, vote) VALUES (?, ?, ?)")
-	statement.Exec(username, postId, vote)
+func AddVote(database *sql.DB, postId int, username string, vote int) error {
+	_, err := database.Exec("INSERT INTO votes (username, post_id, vote) VALUES (?, ?, ?)", username, postId, vote)
+	return dbError(err)
 }
 
-// UpdateVote updates the vote of a user for a post
-func UpdateVote(database *sql.DB, postId int, username string, vote int) {
-	statement, _ := database.Prepare("UPDATE votes SET vote = ? WHERE post_id = ? AND username = ?")
-	statement.Exec(vote, postId, username)
+// UpdateVote updates the vote of a user for a post, ErrNotFound if the user hasn't voted
+func UpdateVote(database *sql.DB, postId int, username string, vote int) error {
+	return execError(database.Exec("UPDATE votes SET vote = ? WHERE post_id = ? AND username = ?", vote, postId, username))
 }
@@ -0,0 +1,115 @@
+package databaseAPI
+
+import (
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
+)
+
+// AddWebhook saves a webhook and returns its id, events and categories are saved as comma separated lists
+func AddWebhook(database *sql.DB, webhook Webhook, createdAt time.Time) (int, error) {
+	createdAtString := FormatTime(createdAt)
+	return insertedId(database.QueryRow("INSERT INTO webhooks (url, secret, events, categories, active, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id", webhook.URL, webhook.Secret, strings.Join(webhook.Events, ","), strings.Join(webhook.Categories, ","), webhook.Active, webhook.CreatedBy, createdAtString))
+}
+
+// GetWebhooks returns all the webhooks
+func GetWebhooks(database *sql.DB) ([]Webhook, error) {
+	rows, err := database.Query("SELECT id, url, secret, events, categories, active, created_by, created_at FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var webhooks []Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhook returns a webhook, ErrNotFound if it doesn't exist
+func GetWebhook(database *sql.DB, id int) (Webhook, error) {
+	webhook, err := scanWebhook(database.QueryRow("SELECT id, url, secret, events, categories, active, created_by, created_at FROM webhooks WHERE id = ?", id))
+	return webhook, dbError(err)
+}
+
+// scanWebhook reads a webhook from a row
+func scanWebhook(row interface{ Scan(...interface{}) error }) (Webhook, error) {
+	var webhook Webhook
+	var events, categories string
+	if err := row.Scan(&webhook.Id, &webhook.URL, &webhook.Secret, &events, &categories, &webhook.Active, &webhook.CreatedBy, &webhook.CreatedAt); err != nil {
+		return webhook, err
+	}
+	if events != "" {
+		webhook.Events = strings.Split(events, ",")
+	}
+	if categories != "" {
+		webhook.Categories = strings.Split(categories, ",")
+	}
+	return webhook, nil
+}
+
+// SetWebhookActive enables or disables a webhook, ErrNotFound if it doesn't exist
+func SetWebhookActive(database *sql.DB, id int, active bool) error {
+	return execError(database.Exec("UPDATE webhooks SET active = ? WHERE id = ?", active, id))
+}
+
+// DeleteWebhook deletes a webhook and its deliveries, ErrNotFound if it doesn't exist
+func DeleteWebhook(database *sql.DB, id int) error {
+	if _, err := database.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return err
+	}
+	return execError(database.Exec("DELETE FROM webhooks WHERE id = ?", id))
+}
+
+// AddWebhookDelivery saves a delivery to attempt as soon as possible and returns its id. The user who acted and the
+// post the event is about, 0 if none, are saved with it to delete it with them.
+func AddWebhookDelivery(database *sql.DB, webhookId int, event string, payload string, username string, postId int, createdAt time.Time) (int, error) {
+	createdAtString := FormatTime(createdAt)
+	return insertedId(database.QueryRow("INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt_at, created_at, username, post_id) VALUES (?, ?, ?, 'pending', ?, ?, ?, ?) RETURNING id", webhookId, event, payload, createdAtString, createdAtString, username, postId))
+}
+
+// GetDueWebhookDeliveries returns the pending deliveries whose next attempt is due, oldest first
+func GetDueWebhookDeliveries(database *sql.DB, now time.Time, limit int) ([]WebhookDelivery, error) {
+	return queryWebhookDeliveries(database, "WHERE d.status = 'pending' AND d.next_attempt_at <= ? ORDER BY d.next_attempt_at, d.id LIMIT ?", FormatTime(now), limit)
+}
+
+// GetWebhookDeliveries returns the latest deliveries, only the ones of a webhook if webhookId isn't 0 and the ones with
+// a status if it isn't empty
+func GetWebhookDeliveries(database *sql.DB, webhookId int, status string, limit int) ([]WebhookDelivery, error) {
+	return queryWebhookDeliveries(database, "WHERE (? = 0 OR d.webhook_id = ?) AND (? = '' OR d.status = ?) ORDER BY d.id DESC LIMIT ?", webhookId, webhookId, status, status, limit)
+}
+
+// queryWebhookDeliveries returns the deliveries matching the end of a query, with the URL of their webhook
+func queryWebhookDeliveries(database *sql.DB, where string, args ...interface{}) ([]WebhookDelivery, error) {
+	rows, err := database.Query("SELECT d.id, d.webhook_id, w.url, d.event, d.payload, d.status, d.attempts, d.last_status_code, d.last_error, d.next_attempt_at, d.created_at, d.delivered_at FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if err := rows.Scan(&delivery.Id, &delivery.WebhookId, &delivery.WebhookURL, &delivery.Event, &delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.LastStatusCode, &delivery.LastError, &delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.DeliveredAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// UpdateWebhookDelivery saves the outcome of an attempt of a delivery
+func UpdateWebhookDelivery(database *sql.DB, delivery WebhookDelivery) error {
+	_, err := database.Exec("UPDATE webhook_deliveries SET status = ?, attempts = ?, last_status_code = ?, last_error = ?, next_attempt_at = ?, delivered_at = ? WHERE id = ?", delivery.Status, delivery.Attempts, delivery.LastStatusCode, delivery.LastError, delivery.NextAttemptAt, delivery.DeliveredAt, delivery.Id)
+	return err
+}
+
+// RetryWebhookDelivery attempts a dead delivery again as soon as possible, with a new set of retries
+func RetryWebhookDelivery(database *sql.DB, id int, now time.Time) error {
+	_, err := database.Exec("UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = ? WHERE id = ? AND status = 'dead'", FormatTime(now), id)
+	return err
+}
@@ -1,11 +1,32 @@
 module FORUM-GO
 
-go 1.18
+go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/alecthomas/chroma v0.10.0
+	github.com/andybalholm/brotli v1.1.1
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.13
+	github.com/prometheus/client_golang v1.20.5
 	github.com/satori/go.uuid v1.2.0
-	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e
+	github.com/yuin/goldmark v1.5.4
+	github.com/yuin/goldmark-highlighting v0.0.0-20220208100518-594be1970594
+	golang.org/x/crypto v0.24.0
+	golang.org/x/image v0.18.0
+	golang.org/x/net v0.26.0
 )
 
-require gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dlclark/regexp2 v1.4.0 // indirect
+	github.com/klauspost/compress v1.17.9 // indirect
+	github.com/kr/text v0.1.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	golang.org/x/sys v0.22.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
+)
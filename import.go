@@ -0,0 +1,99 @@
+package main
+
+import (
+	"FORUM-GO/configAPI"
+	"FORUM-GO/importAPI"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runImport runs the import subcommand, which imports a dump into the database, and returns the exit code
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "format of the file: json, csv, phpbb or discourse, guessed from the extension for json and csv")
+	source := flags.String("source", "", "name of the source, under which the imported ids are kept (default: the source of a JSON dump, or the format and file name)")
+	prefix := flags.String("prefix", "phpbb_", "prefix of the tables of a phpBB dump")
+	dryRun := flags.Bool("dry-run", false, "read and check the whole file without saving anything")
+	keepAdmins := flags.Bool("keep-admins", false, "keep the admins of the source as admins, instead of importing them as plain users")
+	configFlags := configAPI.AddFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: forum import [flags] file")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if !loadConfig(configFlags) {
+		return 2
+	}
+	name := flags.Arg(0)
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(strings.TrimSuffix(name, ".gz"))) {
+		case ".json":
+			*format = "json"
+		case ".csv":
+			*format = "csv"
+		default:
+			fmt.Println("Unable to guess the format of " + name + ", use -format")
+			return 2
+		}
+	}
+	file, err := importAPI.Open(name)
+	if err != nil {
+		fmt.Println("Unable to open " + name + ": " + err.Error())
+		return 1
+	}
+	defer file.Close()
+	var dump importAPI.Dump
+	switch *format {
+	case "json":
+		dump, err = importAPI.ReadJSON(file)
+	case "csv":
+		dump, err = importAPI.ReadCSV(file)
+	case "phpbb":
+		dump, err = importAPI.ReadPhpBB(file, *prefix)
+	case "discourse":
+		dump, err = importAPI.ReadDiscourse(file)
+	default:
+		fmt.Println("Unknown format " + *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Println("Unable to read " + name + ": " + err.Error())
+		return 1
+	}
+	if !*keepAdmins {
+		for i := range dump.Users {
+			dump.Users[i].Admin = false
+		}
+	}
+	if *source != "" {
+		dump.Source = *source
+	} else if dump.Source == "" {
+		dump.Source = *format + ":" + filepath.Base(name)
+	}
+	if err := openDatabase(); err != nil {
+		fmt.Println("Unable to open the database: " + err.Error())
+		return 1
+	}
+	defer database.Close()
+	stats, err := importAPI.Import(database, dump, *dryRun)
+	if err != nil {
+		fmt.Println("Import of " + name + " failed, nothing has been saved: " + err.Error())
+		return 1
+	}
+	fmt.Println(stats)
+	if *dryRun {
+		fmt.Println("Dry run of the import of " + name + " from " + dump.Source + ", nothing has been saved")
+	} else {
+		fmt.Println("Imported " + name + " from " + dump.Source + " at " + time.Now().Format("2006-01-02 15:04:05"))
+	}
+	return 0
+}
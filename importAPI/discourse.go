@@ -0,0 +1,116 @@
+package importAPI
+
+import (
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	discourseRegularPost = "1"
+	discourseLike        = "2"
+	discourseMessage     = "private_message"
+)
+
+// ReadDiscourse reads the users, categories, topics, posts and likes of a Discourse forum from the dump of its
+// PostgreSQL database, as found in its backups. Every public topic becomes a post, its first post giving its content,
+// and its other posts become comments, replying to the post they reply to. The likes of the first posts become
+// upvotes, and the admins stay admins.
+func ReadDiscourse(r io.Reader) (Dump, error) {
+	var dump Dump
+	tables, err := readPostgresDump(r, []string{"users", "user_emails", "categories", "topics", "posts", "post_actions"})
+	if err != nil {
+		return dump, err
+	}
+	emails := map[string]string{}
+	for _, email := range tables["user_emails"] {
+		if email["primary"] == "t" {
+			emails[email["user_id"]] = email["email"]
+		}
+	}
+	usernames := map[string]string{}
+	for _, user := range tables["users"] {
+		usernames[user["id"]] = user["username"]
+		// the users with a negative id are bots, such as system
+		id, _ := strconv.Atoi(user["id"])
+		dump.Users = append(dump.Users, User{Username: user["username"], Email: emails[user["id"]], Admin: user["admin"] == "t" && id > 0})
+	}
+	categories := map[string]string{}
+	for _, category := range tables["categories"] {
+		categories[category["id"]] = category["name"]
+		dump.Categories = append(dump.Categories, Category{Name: category["name"]})
+	}
+	topics := map[string]row{}
+	for _, topic := range tables["topics"] {
+		if topic["deleted_at"] == "" && topic["archetype"] != discourseMessage {
+			topics[topic["id"]] = topic
+		}
+	}
+	// the ids of the posts by topic and number in the topic, to find the post a post replies to
+	numbers := map[string]map[string]string{}
+	firstPosts := map[string]string{}
+	var replies []row
+	for _, post := range tables["posts"] {
+		topic, found := topics[post["topic_id"]]
+		if !found || post["deleted_at"] != "" || post["post_type"] != discourseRegularPost {
+			continue
+		}
+		if post["post_number"] != "1" {
+			if numbers[post["topic_id"]] == nil {
+				numbers[post["topic_id"]] = map[string]string{}
+			}
+			numbers[post["topic_id"]][post["post_number"]] = post["id"]
+			replies = append(replies, post)
+			continue
+		}
+		firstPosts[post["id"]] = topic["id"]
+		var postCategories []string
+		if category, found := categories[topic["category_id"]]; found {
+			postCategories = []string{category}
+		}
+		dump.Posts = append(dump.Posts, Post{
+			Id:         ID(topic["id"]),
+			Username:   usernames[post["user_id"]],
+			Title:      topic["title"],
+			Categories: postCategories,
+			Content:    post["raw"],
+			CreatedAt:  discourseTime(post["created_at"]),
+		})
+	}
+	imported := map[string]bool{}
+	for _, topicId := range firstPosts {
+		imported[topicId] = true
+	}
+	for _, post := range replies {
+		if !imported[post["topic_id"]] {
+			continue
+		}
+		comment := Comment{
+			Id:        ID(post["id"]),
+			PostId:    ID(post["topic_id"]),
+			Username:  usernames[post["user_id"]],
+			Content:   post["raw"],
+			CreatedAt: discourseTime(post["created_at"]),
+		}
+		if parent, found := numbers[post["topic_id"]][post["reply_to_post_number"]]; found {
+			comment.ParentId = ID(parent)
+		}
+		dump.Comments = append(dump.Comments, comment)
+	}
+	for _, action := range tables["post_actions"] {
+		topicId, found := firstPosts[action["post_id"]]
+		if found && action["post_action_type_id"] == discourseLike && action["deleted_at"] == "" {
+			dump.Votes = append(dump.Votes, Vote{PostId: ID(topicId), Username: usernames[action["user_id"]], Vote: 1})
+		}
+	}
+	return dump, nil
+}
+
+// discourseTime converts a time of the Discourse database, in UTC, to RFC 3339
+func discourseTime(value string) string {
+	parsed, err := time.Parse("2006-01-02 15:04:05.999999999", value)
+	if err != nil {
+		return value
+	}
+	return parsed.Format(time.RFC3339)
+}
@@ -0,0 +1,322 @@
+package importAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCategory is the category of the imported posts that have none
+const defaultCategory = "General"
+
+// Dump is the interchange format of the imports, read from JSON or CSV or converted from another forum's export
+type Dump struct {
+	Source     string     `json:"source"`
+	Users      []User     `json:"users"`
+	Categories []Category `json:"categories"`
+	Posts      []Post     `json:"posts"`
+	Comments   []Comment  `json:"comments"`
+	Votes      []Vote     `json:"votes"`
+}
+
+type User struct {
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"passwordHash"`
+	Admin        bool   `json:"admin"`
+}
+
+type Category struct {
+	Name string `json:"name"`
+	Icon string `json:"icon"`
+}
+
+type Post struct {
+	Id         ID       `json:"id"`
+	Username   string   `json:"username"`
+	Title      string   `json:"title"`
+	Categories []string `json:"categories"`
+	Content    string   `json:"content"`
+	CreatedAt  string   `json:"createdAt"`
+}
+
+type Comment struct {
+	Id        ID     `json:"id"`
+	PostId    ID     `json:"postId"`
+	ParentId  ID     `json:"parentId"`
+	Username  string `json:"username"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type Vote struct {
+	PostId   ID     `json:"postId"`
+	Username string `json:"username"`
+	Vote     int    `json:"vote"`
+}
+
+// ID is the id given by the source to a post or a comment, written as a JSON string or number
+type ID string
+
+// UnmarshalJSON reads an id written as a string or a number
+func (id *ID) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case string:
+		*id = ID(value)
+	case json.Number:
+		*id = ID(value.String())
+	case nil:
+		*id = ""
+	default:
+		return errors.New("invalid id " + string(data))
+	}
+	return nil
+}
+
+// Stats counts the added and the skipped data of an import, by kind
+type Stats struct {
+	Added   map[string]int
+	Skipped map[string]int
+}
+
+// String returns the counts of the stats, as "users: 2 added, 1 skipped"
+func (stats Stats) String() string {
+	var lines []string
+	for _, kind := range []string{"users", "categories", "posts", "comments", "votes"} {
+		lines = append(lines, kind+": "+strconv.Itoa(stats.Added[kind])+" added, "+strconv.Itoa(stats.Skipped[kind])+" skipped")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// count counts one added or skipped piece of data
+func (stats Stats) count(kind string, added bool) {
+	if added {
+		stats.Added[kind]++
+	} else {
+		stats.Skipped[kind]++
+	}
+}
+
+// Import writes a dump in the database in a single transaction, which is cancelled at the end if dryRun is true. The
+// users and categories referenced by the posts, comments and votes are created if the dump doesn't list them.
+func Import(database *sql.DB, dump Dump, dryRun bool) (Stats, error) {
+	stats := Stats{Added: map[string]int{}, Skipped: map[string]int{}}
+	comments, err := orderComments(dump.Comments)
+	if err != nil {
+		return stats, err
+	}
+	completeDump(&dump)
+	importer, err := databaseAPI.BeginImport(database, dump.Source)
+	if err != nil {
+		return stats, err
+	}
+	defer importer.Rollback()
+	for _, user := range dump.Users {
+		if user.Username == "" || user.Username == databaseAPI.DeletedUsername {
+			continue
+		}
+		added, err := importer.User(user.Username, user.Email, user.PasswordHash, user.Admin)
+		if err != nil {
+			return stats, fmt.Errorf("user %q: %w", user.Username, err)
+		}
+		stats.count("users", added)
+	}
+	for _, category := range dump.Categories {
+		added, err := importer.Category(category.Name, category.Icon)
+		if err != nil {
+			return stats, fmt.Errorf("category %q: %w", category.Name, err)
+		}
+		stats.count("categories", added)
+	}
+	for _, post := range dump.Posts {
+		createdAt, err := parseTime(post.CreatedAt)
+		if err != nil {
+			return stats, fmt.Errorf("post %q: %w", post.Id, err)
+		}
+		if post.Id == "" || post.Title == "" {
+			return stats, fmt.Errorf("post %q: missing id or title", post.Id)
+		}
+		added, err := importer.Post(string(post.Id), post.Username, post.Title, post.Categories, post.Content, createdAt)
+		if err != nil {
+			return stats, fmt.Errorf("post %q: %w", post.Id, err)
+		}
+		stats.count("posts", added)
+	}
+	for _, comment := range comments {
+		createdAt, err := parseTime(comment.CreatedAt)
+		if err != nil {
+			return stats, fmt.Errorf("comment %q: %w", comment.Id, err)
+		}
+		added, err := importer.Comment(string(comment.Id), string(comment.PostId), string(comment.ParentId), comment.Username, comment.Content, createdAt)
+		if err != nil {
+			return stats, fmt.Errorf("comment %q: %w", comment.Id, err)
+		}
+		stats.count("comments", added)
+	}
+	for _, vote := range dump.Votes {
+		if vote.Vote != 1 && vote.Vote != -1 {
+			return stats, fmt.Errorf("vote of %s for post %q: the vote must be 1 or -1", vote.Username, vote.PostId)
+		}
+		added, err := importer.Vote(string(vote.PostId), vote.Username, vote.Vote)
+		if err != nil {
+			return stats, fmt.Errorf("vote of %s for post %q: %w", vote.Username, vote.PostId, err)
+		}
+		stats.count("votes", added)
+	}
+	if dryRun {
+		return stats, nil
+	}
+	return stats, importer.Commit()
+}
+
+// completeDump adds to a dump the users and the categories referenced but not listed, and the default category to
+// the posts without category
+func completeDump(dump *Dump) {
+	users := map[string]bool{}
+	for _, user := range dump.Users {
+		users[user.Username] = true
+	}
+	addUser := func(username string) {
+		if !users[username] {
+			users[username] = true
+			dump.Users = append(dump.Users, User{Username: username})
+		}
+	}
+	categories := map[string]bool{}
+	for _, category := range dump.Categories {
+		categories[category.Name] = true
+	}
+	for i, post := range dump.Posts {
+		addUser(post.Username)
+		if len(post.Categories) == 0 {
+			dump.Posts[i].Categories = []string{defaultCategory}
+		}
+		for _, category := range dump.Posts[i].Categories {
+			if !categories[category] {
+				categories[category] = true
+				dump.Categories = append(dump.Categories, Category{Name: category})
+			}
+		}
+	}
+	for _, comment := range dump.Comments {
+		addUser(comment.Username)
+	}
+	for _, vote := range dump.Votes {
+		addUser(vote.Username)
+	}
+}
+
+// orderComments returns the comments sorted so that every comment comes after its parent
+func orderComments(comments []Comment) ([]Comment, error) {
+	children := map[ID][]Comment{}
+	ids := map[ID]bool{}
+	for _, comment := range comments {
+		ids[comment.Id] = true
+	}
+	var ordered []Comment
+	for _, comment := range comments {
+		if comment.ParentId == "" || !ids[comment.ParentId] {
+			// a parent missing from the dump may have been imported before, the importer checks it
+			ordered = append(ordered, comment)
+		} else {
+			children[comment.ParentId] = append(children[comment.ParentId], comment)
+		}
+	}
+	for i := 0; i < len(ordered); i++ {
+		ordered = append(ordered, children[ordered[i].Id]...)
+		delete(children, ordered[i].Id)
+	}
+	if len(children) != 0 {
+		return nil, errors.New("the replies of some comments form a cycle")
+	}
+	return ordered, nil
+}
+
+// parseTime parses a time written in RFC 3339, as a Unix timestamp, or in the local time format of the forum's database
+// before its times were saved in UTC
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("missing createdAt")
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	parsed, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	if err != nil {
+		return parsed, errors.New("invalid time " + strconv.Quote(value))
+	}
+	return parsed, nil
+}
+
+// Open opens a file to import, uncompressing it if its name ends with .gz. In a .tar.gz archive, such as a Discourse
+// backup, the file opened is the first one named dump.sql or dump.sql.gz.
+func Open(name string) (io.ReadCloser, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		uncompressed, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		archive := tar.NewReader(uncompressed)
+		for {
+			header, err := archive.Next()
+			if err != nil {
+				file.Close()
+				if err == io.EOF {
+					return nil, errors.New("no dump.sql in " + name)
+				}
+				return nil, err
+			}
+			switch path.Base(header.Name) {
+			case "dump.sql":
+				return readCloser{archive, file}, nil
+			case "dump.sql.gz":
+				dump, err := gzip.NewReader(archive)
+				if err != nil {
+					file.Close()
+					return nil, err
+				}
+				return readCloser{dump, file}, nil
+			}
+		}
+	case strings.HasSuffix(name, ".gz"):
+		uncompressed, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return readCloser{uncompressed, file}, nil
+	}
+	return file, nil
+}
+
+// readCloser reads an uncompressed stream and closes the underlying file
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,94 @@
+package importAPI
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvColumns are the columns of the CSV format, in any order and all optional but type
+var csvColumns = []string{"type", "id", "post_id", "parent_id", "username", "email", "title", "categories", "content", "vote", "created_at"}
+
+// ReadJSON reads a dump in the JSON interchange format
+func ReadJSON(r io.Reader) (Dump, error) {
+	var dump Dump
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&dump)
+	return dump, err
+}
+
+// ReadCSV reads a dump in the CSV interchange format: a header line naming the columns, then one line per user, post,
+// comment or vote, given by the type column. The categories of a post are separated by semicolons.
+func ReadCSV(r io.Reader) (Dump, error) {
+	var dump Dump
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return dump, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !inArray(name, csvColumns) {
+			return dump, errors.New("unknown column " + strconv.Quote(name))
+		}
+		columns[name] = i
+	}
+	if _, found := columns["type"]; !found {
+		return dump, errors.New("missing type column")
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return dump, nil
+		}
+		if err != nil {
+			return dump, err
+		}
+		line, _ := reader.FieldPos(0)
+		get := func(column string) string {
+			i, found := columns[column]
+			if !found || i >= len(record) {
+				return ""
+			}
+			return record[i]
+		}
+		switch get("type") {
+		case "user":
+			dump.Users = append(dump.Users, User{Username: get("username"), Email: get("email")})
+		case "post":
+			var categories []string
+			for _, category := range strings.Split(get("categories"), ";") {
+				if category = strings.TrimSpace(category); category != "" {
+					categories = append(categories, category)
+				}
+			}
+			dump.Posts = append(dump.Posts, Post{Id: ID(get("id")), Username: get("username"), Title: get("title"), Categories: categories, Content: get("content"), CreatedAt: get("created_at")})
+		case "comment":
+			dump.Comments = append(dump.Comments, Comment{Id: ID(get("id")), PostId: ID(get("post_id")), ParentId: ID(get("parent_id")), Username: get("username"), Content: get("content"), CreatedAt: get("created_at")})
+		case "vote":
+			vote, err := strconv.Atoi(get("vote"))
+			if err != nil {
+				return dump, errors.New("line " + strconv.Itoa(line) + ": invalid vote " + strconv.Quote(get("vote")))
+			}
+			dump.Votes = append(dump.Votes, Vote{PostId: ID(get("post_id")), Username: get("username"), Vote: vote})
+		default:
+			return dump, errors.New("line " + strconv.Itoa(line) + ": invalid type " + strconv.Quote(get("type")))
+		}
+	}
+}
+
+// inArray returns true if the string is in the array
+func inArray(value string, array []string) bool {
+	for _, item := range array {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
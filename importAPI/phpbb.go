@@ -0,0 +1,185 @@
+package importAPI
+
+import (
+	"html"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// phpBB user types
+const (
+	phpbbUserIgnore  = "2"
+	phpbbUserFounder = "3"
+)
+
+// phpbbForumPost is the type of the forums holding topics, the others being categories of forums and links
+const phpbbForumPost = "1"
+
+var (
+	xmlTag       = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+	legacySmiley = regexp.MustCompile(`<!-- s(\S+) --><img[^>]*><!-- s\S+ -->`)
+	legacyLink   = regexp.MustCompile(`<!-- [mlwe] --><a[^>]*href="([^"]*)"[^>]*>.*?</a><!-- [mlwe] -->`)
+	quoteOpening = regexp.MustCompile(`(?i)\[quote(=[^\]]*)?\]`)
+	quoteAuthor  = regexp.MustCompile(`^=\s*(?:"([^"]*)"|([^\s\]]+))`)
+)
+
+// bbcodes are the replacements of the BBCode tags by Markdown, the quotes being replaced by replaceQuotes
+var bbcodes = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?is)\[code(?:=[^\]]*)?\](.*?)\[/code\]`), "\n```\n$1\n```\n"},
+	{regexp.MustCompile(`(?is)\[b\](.*?)\[/b\]`), "**$1**"},
+	{regexp.MustCompile(`(?is)\[i\](.*?)\[/i\]`), "*$1*"},
+	{regexp.MustCompile(`(?is)\[u\](.*?)\[/u\]`), "$1"},
+	{regexp.MustCompile(`(?is)\[url=([^\]]+)\](.*?)\[/url\]`), "[$2]($1)"},
+	{regexp.MustCompile(`(?is)\[url\](.*?)\[/url\]`), "<$1>"},
+	{regexp.MustCompile(`(?is)\[email=([^\]]+)\](.*?)\[/email\]`), "[$2](mailto:$1)"},
+	{regexp.MustCompile(`(?is)\[email\](.*?)\[/email\]`), "<$1>"},
+	{regexp.MustCompile(`(?is)\[img\](.*?)\[/img\]`), "![]($1)"},
+	{regexp.MustCompile(`(?is)\[(?:size|color|font)=[^\]]*\](.*?)\[/(?:size|color|font)\]`), "$1"},
+	{regexp.MustCompile(`(?i)\[list(?:=[^\]]*)?\]\s*`), "\n"},
+	{regexp.MustCompile(`(?i)\s*\[/list(?::[uo])?\]`), "\n"},
+	{regexp.MustCompile(`(?i)\[\*\]\s*`), "\n- "},
+	{regexp.MustCompile(`(?i)\[/\*(?::m)?\]`), ""},
+}
+
+// ReadPhpBB reads the users, forums, topics and posts of a phpBB 3 forum from a dump of its MySQL database, whose
+// tables are named with the prefix. Every topic becomes a post, its first post giving its content, and its other
+// posts become comments. The forums become categories, and the founders admins.
+func ReadPhpBB(r io.Reader, prefix string) (Dump, error) {
+	var dump Dump
+	tables, err := readMysqlDump(r, []string{prefix + "users", prefix + "forums", prefix + "topics", prefix + "posts"})
+	if err != nil {
+		return dump, err
+	}
+	usernames := map[string]string{}
+	for _, user := range tables[prefix+"users"] {
+		if user["user_type"] == phpbbUserIgnore {
+			continue
+		}
+		username := html.UnescapeString(user["username"])
+		usernames[user["user_id"]] = username
+		dump.Users = append(dump.Users, User{Username: username, Email: user["user_email"], PasswordHash: user["user_password"], Admin: user["user_type"] == phpbbUserFounder})
+	}
+	forums := map[string]string{}
+	for _, forum := range tables[prefix+"forums"] {
+		if forum["forum_type"] == phpbbForumPost {
+			forums[forum["forum_id"]] = html.UnescapeString(forum["forum_name"])
+			dump.Categories = append(dump.Categories, Category{Name: forums[forum["forum_id"]]})
+		}
+	}
+	topics := map[string]row{}
+	for _, topic := range tables[prefix+"topics"] {
+		// moved topics leave a shadow topic in their former forum
+		if (topic["topic_moved_id"] != "" && topic["topic_moved_id"] != "0") || !phpbbVisible(topic, "topic") {
+			continue
+		}
+		if _, found := forums[topic["forum_id"]]; found {
+			topics[topic["topic_id"]] = topic
+		}
+	}
+	imported := map[string]bool{}
+	var replies []row
+	for _, post := range tables[prefix+"posts"] {
+		topic, found := topics[post["topic_id"]]
+		if !found || !phpbbVisible(post, "post") {
+			continue
+		}
+		if post["post_id"] != topic["topic_first_post_id"] {
+			replies = append(replies, post)
+			continue
+		}
+		imported[topic["topic_id"]] = true
+		dump.Posts = append(dump.Posts, Post{
+			Id:         ID(topic["topic_id"]),
+			Username:   phpbbPoster(post, usernames),
+			Title:      html.UnescapeString(topic["topic_title"]),
+			Categories: []string{forums[topic["forum_id"]]},
+			Content:    phpbbText(post["post_text"], post["bbcode_uid"]),
+			CreatedAt:  post["post_time"],
+		})
+	}
+	for _, post := range replies {
+		if imported[post["topic_id"]] {
+			dump.Comments = append(dump.Comments, Comment{
+				Id:        ID(post["post_id"]),
+				PostId:    ID(post["topic_id"]),
+				Username:  phpbbPoster(post, usernames),
+				Content:   phpbbText(post["post_text"], post["bbcode_uid"]),
+				CreatedAt: post["post_time"],
+			})
+		}
+	}
+	return dump, nil
+}
+
+// phpbbVisible returns true if a topic or a post is approved, from the visibility column of phpBB 3.1 or the
+// approved column of phpBB 3.0
+func phpbbVisible(values row, kind string) bool {
+	if visibility, found := values[kind+"_visibility"]; found {
+		return visibility == "1"
+	}
+	return values[kind+"_approved"] != "0"
+}
+
+// phpbbPoster returns the username of the author of a post, or the name given by a guest
+func phpbbPoster(post row, usernames map[string]string) string {
+	if username, found := usernames[post["poster_id"]]; found {
+		return username
+	}
+	if post["post_username"] != "" {
+		return html.UnescapeString(post["post_username"])
+	}
+	return "Guest"
+}
+
+// phpbbText converts the text of a phpBB post to Markdown
+func phpbbText(text string, uid string) string {
+	if strings.HasPrefix(text, "<r>") || strings.HasPrefix(text, "<t>") {
+		// since phpBB 3.2, the original text is stored marked up in XML
+		text = strings.NewReplacer("<br/>", "\n", "<br />", "\n").Replace(text)
+		text = xmlTag.ReplaceAllString(text, "")
+	} else {
+		if uid != "" {
+			text = strings.ReplaceAll(text, ":"+uid+"]", "]")
+		}
+		text = legacySmiley.ReplaceAllString(text, "$1")
+		text = legacyLink.ReplaceAllString(text, "$1")
+		text = strings.ReplaceAll(text, "<br />", "\n")
+	}
+	return strings.TrimSpace(bbcodeToMarkdown(html.UnescapeString(text)))
+}
+
+// bbcodeToMarkdown converts the common BBCode tags to Markdown
+func bbcodeToMarkdown(text string) string {
+	text = replaceQuotes(text)
+	for _, bbcode := range bbcodes {
+		text = bbcode.pattern.ReplaceAllString(text, bbcode.replacement)
+	}
+	return text
+}
+
+// replaceQuotes converts the quotes to Markdown blockquotes, from the innermost
+func replaceQuotes(text string) string {
+	for {
+		openings := quoteOpening.FindAllStringSubmatchIndex(text, -1)
+		if openings == nil {
+			return text
+		}
+		opening := openings[len(openings)-1]
+		end := strings.Index(strings.ToLower(text[opening[1]:]), "[/quote]")
+		if end < 0 {
+			return text
+		}
+		quoted := strings.TrimSpace(text[opening[1] : opening[1]+end])
+		if opening[2] >= 0 {
+			if author := quoteAuthor.FindStringSubmatch(text[opening[2]:opening[3]]); author != nil {
+				quoted = "**" + author[1] + author[2] + " wrote:**\n" + quoted
+			}
+		}
+		quoted = "\n> " + strings.ReplaceAll(quoted, "\n", "\n> ") + "\n\n"
+		text = text[:opening[0]] + quoted + text[opening[1]+end+len("[/quote]"):]
+	}
+}
@@ -0,0 +1,408 @@
+package importAPI
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// row is a row of a table read from an SQL dump, by column name. NULL values are read as empty strings.
+type row map[string]string
+
+// readMysqlDump reads the rows of some tables from a dump made by mysqldump. The names of the columns come from the
+// CREATE TABLE statements, or from the INSERT statements of a dump made with --complete-insert.
+func readMysqlDump(r io.Reader, tables []string) (map[string][]row, error) {
+	rows := map[string][]row{}
+	columns := map[string][]string{}
+	reader := bufio.NewReader(r)
+	for {
+		statement, err := readMysqlStatement(reader)
+		if statement != "" {
+			parser := &sqlParser{text: statement}
+			switch {
+			case parser.keywords("CREATE", "TABLE"):
+				parser.keywords("IF", "NOT", "EXISTS")
+				table := parser.identifier()
+				if inArray(table, tables) {
+					columns[table] = parser.columnDefinitions()
+				}
+			case parser.keywords("INSERT", "INTO") || parser.keywords("INSERT", "IGNORE", "INTO") || parser.keywords("REPLACE", "INTO"):
+				table := parser.identifier()
+				if !inArray(table, tables) {
+					break
+				}
+				tableRows, err := parser.insertedRows(columns[table])
+				if err != nil {
+					return nil, errors.New(table + ": " + err.Error())
+				}
+				rows[table] = append(rows[table], tableRows...)
+			}
+		}
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readMysqlStatement reads the next statement of a MySQL dump, without its comments
+func readMysqlStatement(reader *bufio.Reader) (string, error) {
+	var statement strings.Builder
+	var quote rune
+	for {
+		c, _, err := reader.ReadRune()
+		if err != nil {
+			return strings.TrimSpace(statement.String()), err
+		}
+		if quote != 0 {
+			statement.WriteRune(c)
+			if c == '\\' && quote == '\'' {
+				escaped, _, err := reader.ReadRune()
+				if err != nil {
+					return "", io.ErrUnexpectedEOF
+				}
+				statement.WriteRune(escaped)
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+			statement.WriteRune(c)
+		case ';':
+			return strings.TrimSpace(statement.String()), nil
+		case '#':
+			reader.ReadString('\n')
+		case '-':
+			next, _ := reader.Peek(2)
+			if len(next) == 2 && next[0] == '-' && (next[1] == ' ' || next[1] == '\n' || next[1] == '\t') {
+				reader.ReadString('\n')
+			} else {
+				statement.WriteRune(c)
+			}
+		case '/':
+			next, _ := reader.Peek(1)
+			if len(next) == 1 && next[0] == '*' {
+				// the conditional comments of mysqldump only hold session settings
+				if err := skipBlockComment(reader); err != nil {
+					return "", err
+				}
+			} else {
+				statement.WriteRune(c)
+			}
+		default:
+			statement.WriteRune(c)
+		}
+	}
+}
+
+// skipBlockComment skips a /* */ comment, the reader being on its star
+func skipBlockComment(reader *bufio.Reader) error {
+	reader.ReadByte()
+	previous := byte(0)
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		if previous == '*' && c == '/' {
+			return nil
+		}
+		previous = c
+	}
+}
+
+// sqlParser reads the parts of a MySQL statement
+type sqlParser struct {
+	text string
+	pos  int
+}
+
+// skipSpace moves past the spaces
+func (p *sqlParser) skipSpace() {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+// keywords moves past the given keywords if the statement continues with them, and returns true in that case
+func (p *sqlParser) keywords(keywords ...string) bool {
+	start := p.pos
+	for _, keyword := range keywords {
+		p.skipSpace()
+		end := p.pos + len(keyword)
+		if end > len(p.text) || !strings.EqualFold(p.text[p.pos:end], keyword) || (end < len(p.text) && isIdentifierChar(p.text[end])) {
+			p.pos = start
+			return false
+		}
+		p.pos = end
+	}
+	return true
+}
+
+// identifier reads a name, quoted with backticks or not
+func (p *sqlParser) identifier() string {
+	p.skipSpace()
+	if p.pos < len(p.text) && p.text[p.pos] == '`' {
+		end := strings.IndexByte(p.text[p.pos+1:], '`')
+		if end < 0 {
+			p.pos = len(p.text)
+			return ""
+		}
+		name := p.text[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return name
+	}
+	start := p.pos
+	for p.pos < len(p.text) && isIdentifierChar(p.text[p.pos]) {
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+// next returns the next character after the spaces, without moving past it
+func (p *sqlParser) next() byte {
+	p.skipSpace()
+	if p.pos >= len(p.text) {
+		return 0
+	}
+	return p.text[p.pos]
+}
+
+// columnDefinitions returns the names of the columns defined by a CREATE TABLE statement, ignoring the keys
+func (p *sqlParser) columnDefinitions() []string {
+	var columns []string
+	if p.next() != '(' {
+		return nil
+	}
+	p.pos++
+	for p.next() != 0 && p.next() != ')' {
+		if p.next() == '`' {
+			columns = append(columns, p.identifier())
+		}
+		// skip the rest of the definition, up to the next comma outside of parentheses and quotes
+		depth := 0
+		for ; p.pos < len(p.text); p.pos++ {
+			c := p.text[p.pos]
+			if c == '\'' {
+				p.value()
+				p.pos--
+			} else if c == '(' {
+				depth++
+			} else if c == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			} else if c == ',' && depth == 0 {
+				p.pos++
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// insertedRows reads the column names, if given, and the rows of an INSERT statement
+func (p *sqlParser) insertedRows(columns []string) ([]row, error) {
+	if p.next() == '(' {
+		columns = nil
+		p.pos++
+		for p.next() != ')' {
+			if p.next() == 0 {
+				return nil, errors.New("unterminated column list")
+			}
+			columns = append(columns, p.identifier())
+			if p.next() == ',' {
+				p.pos++
+			}
+		}
+		p.pos++
+	}
+	if columns == nil {
+		return nil, errors.New("unknown columns, the dump needs its CREATE TABLE statements or --complete-insert")
+	}
+	if !p.keywords("VALUES") && !p.keywords("VALUE") {
+		return nil, errors.New("missing VALUES")
+	}
+	var rows []row
+	for p.next() == '(' {
+		p.pos++
+		values := row{}
+		for i := 0; p.next() != ')'; i++ {
+			if p.next() == 0 {
+				return nil, errors.New("unterminated row")
+			}
+			value := p.value()
+			if i < len(columns) {
+				values[columns[i]] = value
+			}
+			if p.next() == ',' {
+				p.pos++
+			}
+		}
+		p.pos++
+		rows = append(rows, values)
+		if p.next() == ',' {
+			p.pos++
+		}
+	}
+	return rows, nil
+}
+
+// value reads a value: a quoted string, NULL or a number
+func (p *sqlParser) value() string {
+	p.skipSpace()
+	if strings.HasPrefix(p.text[p.pos:], "_binary") {
+		p.pos += len("_binary")
+		p.skipSpace()
+	}
+	if p.pos >= len(p.text) || p.text[p.pos] != '\'' {
+		start := p.pos
+		for p.pos < len(p.text) && p.text[p.pos] != ',' && p.text[p.pos] != ')' {
+			p.pos++
+		}
+		value := strings.TrimSpace(p.text[start:p.pos])
+		if strings.EqualFold(value, "NULL") {
+			return ""
+		}
+		return value
+	}
+	var value strings.Builder
+	for p.pos++; p.pos < len(p.text); p.pos++ {
+		c := p.text[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.text):
+			p.pos++
+			value.WriteString(unescapeMysql(p.text[p.pos]))
+		case c == '\'' && p.pos+1 < len(p.text) && p.text[p.pos+1] == '\'':
+			p.pos++
+			value.WriteByte('\'')
+		case c == '\'':
+			p.pos++
+			return value.String()
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return value.String()
+}
+
+// unescapeMysql returns the character escaped by a backslash in a MySQL string
+func unescapeMysql(c byte) string {
+	switch c {
+	case '0':
+		return "\x00"
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case 'Z':
+		return "\x1a"
+	case 'b':
+		return "\b"
+	}
+	return string(c)
+}
+
+// isIdentifierChar returns true if the character can be part of an unquoted name
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// readPostgresDump reads the rows of some tables from a plain dump made by pg_dump, such as the one of a Discourse
+// backup. The rows are read from the COPY statements, the schema of the tables is ignored.
+func readPostgresDump(r io.Reader, tables []string) (map[string][]row, error) {
+	rows := map[string][]row{}
+	reader := bufio.NewReader(r)
+	var table string
+	var columns []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF && line == "" {
+			return rows, nil
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		switch {
+		case columns != nil && line == `\.`:
+			columns = nil
+		case columns != nil:
+			values := row{}
+			for i, field := range strings.Split(line, "\t") {
+				if i < len(columns) && field != `\N` {
+					values[columns[i]] = unescapePostgres(field)
+				}
+			}
+			rows[table] = append(rows[table], values)
+		case strings.HasPrefix(line, "COPY ") && strings.HasSuffix(line, "FROM stdin;"):
+			open, end := strings.IndexByte(line, '('), strings.LastIndexByte(line, ')')
+			if open < 0 || end < open {
+				continue
+			}
+			table = strings.Trim(strings.TrimSpace(line[len("COPY "):open]), `"`)
+			if dot := strings.LastIndexByte(table, '.'); dot >= 0 {
+				table = strings.Trim(table[dot+1:], `"`)
+			}
+			if !inArray(table, tables) {
+				// skip the rows of the other tables
+				for {
+					skipped, err := reader.ReadString('\n')
+					if err != nil || strings.TrimRight(skipped, "\r\n") == `\.` {
+						break
+					}
+				}
+				continue
+			}
+			columns = []string{}
+			for _, column := range strings.Split(line[open+1:end], ",") {
+				columns = append(columns, strings.Trim(strings.TrimSpace(column), `"`))
+			}
+		}
+		if err == io.EOF {
+			return rows, nil
+		}
+	}
+}
+
+// unescapePostgres decodes a field of the text format of COPY
+func unescapePostgres(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var value strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] != '\\' || i+1 == len(field) {
+			value.WriteByte(field[i])
+			continue
+		}
+		i++
+		switch field[i] {
+		case 'n':
+			value.WriteByte('\n')
+		case 'r':
+			value.WriteByte('\r')
+		case 't':
+			value.WriteByte('\t')
+		case 'b':
+			value.WriteByte('\b')
+		case 'f':
+			value.WriteByte('\f')
+		case 'v':
+			value.WriteByte('\v')
+		default:
+			value.WriteByte(field[i])
+		}
+	}
+	return value.String()
+}
@@ -0,0 +1,184 @@
+package importAPI
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadMysqlDump(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+		want map[string][]row
+	}{
+		{
+			name: "columns from CREATE TABLE",
+			dump: "CREATE TABLE `users` (\n" +
+				"  `user_id` int(10) unsigned NOT NULL AUTO_INCREMENT,\n" +
+				"  `username` varchar(255) NOT NULL DEFAULT '',\n" +
+				"  `user_type` tinyint(2) NOT NULL DEFAULT '0',\n" +
+				"  PRIMARY KEY (`user_id`),\n" +
+				"  KEY `username` (`username`)\n" +
+				") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+				"INSERT INTO `users` VALUES (1,'alice',3),(2,'bob',0);\n",
+			want: map[string][]row{"users": {
+				{"user_id": "1", "username": "alice", "user_type": "3"},
+				{"user_id": "2", "username": "bob", "user_type": "0"},
+			}},
+		},
+		{
+			name: "columns from a complete insert",
+			dump: "INSERT INTO `users` (`user_id`, `username`) VALUES (1, 'alice');",
+			want: map[string][]row{"users": {{"user_id": "1", "username": "alice"}}},
+		},
+		{
+			name: "unquoted names and other inserts",
+			dump: "CREATE TABLE IF NOT EXISTS users (`user_id` int, `username` text);\n" +
+				"INSERT IGNORE INTO users VALUES (1,'alice');\n" +
+				"REPLACE INTO users VALUES (2,'bob');\n",
+			want: map[string][]row{"users": {{"user_id": "1", "username": "alice"}, {"user_id": "2", "username": "bob"}}},
+		},
+		{
+			name: "comments and session settings",
+			dump: "-- MySQL dump 10.13\n" +
+				"/*!40101 SET NAMES utf8mb4 */;\n" +
+				"# a comment\n" +
+				"/*!40000 ALTER TABLE `users` DISABLE KEYS */;\n" +
+				"INSERT INTO `users` (`user_id`, `username`) VALUES (1,'alice'); -- the end\n",
+			want: map[string][]row{"users": {{"user_id": "1", "username": "alice"}}},
+		},
+		{
+			name: "escapes in strings",
+			dump: `INSERT INTO users (user_id, username) VALUES (1,'it\'s'),(2,'it''s'),(3,'a\nb\tc\\d'),(4,'a;b -- c /* d */');`,
+			want: map[string][]row{"users": {
+				{"user_id": "1", "username": "it's"},
+				{"user_id": "2", "username": "it's"},
+				{"user_id": "3", "username": "a\nb\tc\\d"},
+				{"user_id": "4", "username": "a;b -- c /* d */"},
+			}},
+		},
+		{
+			name: "NULL, negative numbers and binary strings",
+			dump: "INSERT INTO users (user_id, username, user_type) VALUES (-1, NULL, _binary 'x');",
+			want: map[string][]row{"users": {{"user_id": "-1", "username": "", "user_type": "x"}}},
+		},
+		{
+			name: "other tables are skipped",
+			dump: "CREATE TABLE `sessions` (`id` int);\n" +
+				"INSERT INTO `sessions` VALUES (1);\n" +
+				"INSERT INTO `users` (`user_id`) VALUES (1);\n",
+			want: map[string][]row{"users": {{"user_id": "1"}}},
+		},
+		{
+			name: "statement without a final semicolon",
+			dump: "INSERT INTO users (user_id) VALUES (1)",
+			want: map[string][]row{"users": {{"user_id": "1"}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readMysqlDump(strings.NewReader(test.dump), []string{"users"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadMysqlDumpErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+	}{
+		{"unknown columns", "INSERT INTO users VALUES (1,'alice');"},
+		{"unterminated row", "INSERT INTO users (user_id) VALUES (1"},
+		{"missing values", "INSERT INTO users (user_id) SELECT 1;"},
+		{"unterminated comment", "/* SET NAMES utf8"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := readMysqlDump(strings.NewReader(test.dump), []string{"users"}); err == nil {
+				t.Errorf("readMysqlDump(%q) returned no error", test.dump)
+			}
+		})
+	}
+}
+
+func TestReadPostgresDump(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+		want map[string][]row
+	}{
+		{
+			name: "COPY rows",
+			dump: "SET statement_timeout = 0;\n" +
+				"COPY public.users (id, username, admin) FROM stdin;\n" +
+				"1\talice\tt\n" +
+				"2\tbob\tf\n" +
+				"\\.\n",
+			want: map[string][]row{"users": {
+				{"id": "1", "username": "alice", "admin": "t"},
+				{"id": "2", "username": "bob", "admin": "f"},
+			}},
+		},
+		{
+			name: "quoted names",
+			dump: "COPY \"public\".\"users\" (\"id\", \"username\") FROM stdin;\n1\talice\n\\.\n",
+			want: map[string][]row{"users": {{"id": "1", "username": "alice"}}},
+		},
+		{
+			name: "NULL and escapes",
+			dump: "COPY users (id, username, bio) FROM stdin;\n" +
+				"1\t\\N\ta\\nb\\tc\\\\d\n" +
+				"\\.\n",
+			want: map[string][]row{"users": {{"id": "1", "bio": "a\nb\tc\\d"}}},
+		},
+		{
+			name: "other tables are skipped",
+			dump: "COPY public.sessions (id, data) FROM stdin;\n" +
+				"1\tCOPY public.users (id) FROM stdin;\n" +
+				"\\.\n" +
+				"COPY public.users (id) FROM stdin;\n" +
+				"1\n" +
+				"\\.\n",
+			want: map[string][]row{"users": {{"id": "1"}}},
+		},
+		{
+			name: "Windows line endings and no final line break",
+			dump: "COPY users (id, username) FROM stdin;\r\n1\talice\r\n\\.",
+			want: map[string][]row{"users": {{"id": "1", "username": "alice"}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readPostgresDump(strings.NewReader(test.dump), []string{"users"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadPhpBBUsers(t *testing.T) {
+	dump := "INSERT INTO `phpbb_users` (`user_id`, `user_type`, `username`, `user_email`, `user_password`) VALUES " +
+		"(1,2,'Anonymous','',''),(2,3,'admin','admin@example.com','$2y$10$hash'),(3,0,'Tom &amp; Jerry','tom@example.com','');"
+	got, err := ReadPhpBB(strings.NewReader(dump), "phpbb_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []User{
+		{Username: "admin", Email: "admin@example.com", PasswordHash: "$2y$10$hash", Admin: true},
+		{Username: "Tom & Jerry", Email: "tom@example.com"},
+	}
+	if !reflect.DeepEqual(got.Users, want) {
+		t.Errorf("got %v, want %v", got.Users, want)
+	}
+}
@@ -0,0 +1,109 @@
+package logAPI
+
+// The audit events, logged with Audit
+
+type UserRegistered struct {
+	Username string
+	Email    string
+}
+
+type LoginFailed struct {
+	Email  string
+	Reason string
+}
+
+type LoggedIn struct {
+	Username string
+	Email    string
+}
+
+type LoggedOut struct {
+	Username string
+}
+
+type EmailChanged struct {
+	Username string
+	Email    string
+}
+
+type PasswordChanged struct {
+	Username string
+}
+
+type PostCreated struct {
+	Username string
+	PostId   int
+	Title    string
+}
+
+type CommentCreated struct {
+	Username  string
+	PostId    int
+	CommentId int
+}
+
+// VoteCast is a vote of 1 or -1 on a post, or 0 when the user removes their vote
+type VoteCast struct {
+	Username string
+	PostId   int
+	Vote     int
+}
+
+type ReportOpened struct {
+	Username string
+	PostId   int
+}
+
+type DataExported struct {
+	Username string
+}
+
+type AccountDeleted struct {
+	Username string
+	Mode     string
+}
+
+type WebhookCreated struct {
+	Username string
+	URL      string `log:"url"`
+}
+
+// WebhookChanged is the action of an admin on a webhook: enable, disable or delete
+type WebhookChanged struct {
+	Username  string
+	WebhookId int
+	Action    string
+}
+
+type WebhookDeadLettered struct {
+	DeliveryId int
+	URL        string `log:"url"`
+	Error      string
+}
+
+type DigestSent struct {
+	Username string
+}
+
+type BackupCreated struct {
+	Path string
+	By   string
+}
+
+func (UserRegistered) Type() string      { return "user registered" }
+func (LoginFailed) Type() string         { return "login failed" }
+func (LoggedIn) Type() string            { return "logged in" }
+func (LoggedOut) Type() string           { return "logged out" }
+func (EmailChanged) Type() string        { return "email changed" }
+func (PasswordChanged) Type() string     { return "password changed" }
+func (PostCreated) Type() string         { return "post created" }
+func (CommentCreated) Type() string      { return "comment created" }
+func (VoteCast) Type() string            { return "vote cast" }
+func (ReportOpened) Type() string        { return "report opened" }
+func (DataExported) Type() string        { return "data exported" }
+func (AccountDeleted) Type() string      { return "account deleted" }
+func (WebhookCreated) Type() string      { return "webhook created" }
+func (WebhookChanged) Type() string      { return "webhook changed" }
+func (WebhookDeadLettered) Type() string { return "webhook dead-lettered" }
+func (DigestSent) Type() string          { return "digest sent" }
+func (BackupCreated) Type() string       { return "backup created" }
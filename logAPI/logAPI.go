@@ -0,0 +1,86 @@
+package logAPI
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// New returns a logger writing to w in the text or json format, from the level debug, info, warn or error
+func New(w io.Writer, format string, level string) (*slog.Logger, error) {
+	var minLevel slog.Level
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, errors.New("unknown log level " + level)
+	}
+	options := &slog.HandlerOptions{Level: minLevel}
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, errors.New("unknown log format " + format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// contextHandler adds the request ID of the context to the records
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Event is an audit event of the forum. It is logged with its type as message and its fields as attributes, named
+// after their log tag or else their name in snake case.
+type Event interface {
+	Type() string
+}
+
+// Audit logs an audit event at the info level
+func Audit(ctx context.Context, logger *slog.Logger, event Event) {
+	value := reflect.ValueOf(event)
+	attrs := make([]slog.Attr, 0, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := field.Tag.Get("log")
+		if name == "" {
+			name = snakeCase(field.Name)
+		}
+		attrs = append(attrs, slog.Any(name, value.Field(i).Interface()))
+	}
+	logger.LogAttrs(ctx, slog.LevelInfo, event.Type(), attrs...)
+}
+
+// snakeCase returns a field name such as PostId in snake case, as post_id
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
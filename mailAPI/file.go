@@ -0,0 +1,37 @@
+package mailAPI
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FileMailer writes emails as .eml files in a directory instead of sending them
+type FileMailer struct {
+	Dir   string
+	lock  sync.Mutex
+	count int
+}
+
+// NewFileMailer creates the directory if needed and returns a mailer writing in it
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir}, nil
+}
+
+// Send writes a message to a new file
+func (mailer *FileMailer) Send(message Message) error {
+	content, err := message.Bytes()
+	if err != nil {
+		return err
+	}
+	mailer.lock.Lock()
+	mailer.count++
+	name := time.Now().Format("20060102-150405") + "-" + strconv.Itoa(mailer.count) + ".eml"
+	mailer.lock.Unlock()
+	return os.WriteFile(filepath.Join(mailer.Dir, name), content, 0644)
+}
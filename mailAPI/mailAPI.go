@@ -0,0 +1,101 @@
+package mailAPI
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is an email with a plain text and an HTML version
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	Html    string
+	// Headers are added to the standard ones, for example List-Unsubscribe
+	Headers map[string]string
+}
+
+// Mailer sends emails, SMTPMailer is used in production and FileMailer to inspect emails during development and tests
+type Mailer interface {
+	Send(message Message) error
+}
+
+// Bytes returns the message in the RFC 5322 format, as a multipart/alternative MIME message
+func (message Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	headers := map[string]string{
+		"From":         message.From,
+		"To":           message.To,
+		"Subject":      mime.QEncoding.Encode("utf-8", message.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"Message-Id":   "<" + randomId() + "@" + domain(message.From) + ">",
+		"Mime-Version": "1.0",
+		"Content-Type": "multipart/alternative; boundary=" + writer.Boundary(),
+	}
+	for name, value := range message.Headers {
+		headers[name] = value
+	}
+	var head bytes.Buffer
+	for _, name := range []string{"From", "To", "Subject", "Date", "Message-Id", "Mime-Version", "Content-Type"} {
+		fmt.Fprintf(&head, "%s: %s\r\n", name, headerValue(headers[name]))
+		delete(headers, name)
+	}
+	for name, value := range headers {
+		fmt.Fprintf(&head, "%s: %s\r\n", textproto.CanonicalMIMEHeaderKey(name), headerValue(value))
+	}
+	head.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", message.Text},
+		{"text/html; charset=utf-8", message.Html},
+	} {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(partWriter)
+		if _, err := encoder.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+// headerValue removes the line breaks that would allow to inject headers
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// randomId returns a random hexadecimal string
+func randomId() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// domain returns the domain of an email address, or localhost
+func domain(address string) string {
+	address = strings.TrimSuffix(address, ">")
+	if i := strings.LastIndex(address, "@"); i != -1 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
@@ -0,0 +1,40 @@
+package mailAPI
+
+import (
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// SMTPMailer sends emails through an SMTP server, STARTTLS is used when the server supports it
+type SMTPMailer struct {
+	// Addr is the host:port of the server
+	Addr     string
+	Username string
+	Password string
+}
+
+// Send sends a message
+func (mailer SMTPMailer) Send(message Message) error {
+	from, err := mail.ParseAddress(message.From)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return err
+	}
+	content, err := message.Bytes()
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if mailer.Username != "" {
+		host, _, err := net.SplitHostPort(mailer.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, host)
+	}
+	return smtp.SendMail(mailer.Addr, auth, from.Address, []string{to.Address}, content)
+}
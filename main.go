@@ -1,13 +1,24 @@
 package main
 
 import (
+	"FORUM-GO/configAPI"
 	"FORUM-GO/databaseAPI"
+	"FORUM-GO/logAPI"
+	"FORUM-GO/mailAPI"
+	"FORUM-GO/metricsAPI"
+	"FORUM-GO/storageAPI"
 	"FORUM-GO/webAPI"
+	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 type Post struct {
@@ -33,48 +44,271 @@ type Comment struct {
 // Database
 var database *sql.DB
 
+// store holds the users, sessions, posts, comments, votes and categories of the database
+var store databaseAPI.Store
+
 func main() {
-	// check if DB exists
-	var _, err = os.Stat("database.db")
-
-	// create DB if not exists
-	if os.IsNotExist(err) {
-		var file, err = os.Create("database.db")
-		if err != nil {
-			return
-		}
-		defer file.Close()
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
+	switch command {
+	case "serve":
+		os.Exit(runServe(args))
+	case "config":
+		os.Exit(runConfig(args))
+	case "import":
+		os.Exit(runImport(args))
+	case "backup":
+		os.Exit(runBackup(args))
+	case "restore":
+		os.Exit(runRestore(args))
+	case "migrate":
+		os.Exit(runMigrate(args))
+	case "user":
+		os.Exit(runUser(args))
+	case "category":
+		os.Exit(runCategory(args))
+	case "post":
+		os.Exit(runPost(args))
+	case "recount-votes":
+		os.Exit(runRecountVotes(args))
+	case "stats":
+		os.Exit(runStats(args))
+	default:
+		fmt.Println("Unknown command " + command + ", the commands are serve, config, migrate, user, category, post, " +
+			"recount-votes, stats, import, backup and restore")
+		os.Exit(2)
+	}
+}
 
-	database, _ = sql.Open("sqlite3", "./database.db")
+// runServe runs the serve subcommand, the default one, which runs the forum until it fails, and returns the exit code
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configFlags := configAPI.AddFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: forum [serve] [flags]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+	if !loadConfig(configFlags) {
+		return 2
+	}
+
+	if err := openDatabase(); err != nil {
+		logger.Error("unable to open the database", "database", databaseName(), "err", err)
+		return 1
+	}
 
-	databaseAPI.CreateUsersTable(database)
-	databaseAPI.CreatePostTable(database)
-	databaseAPI.CreateCommentTable(database)
-	databaseAPI.CreateVoteTable(database)
-	databaseAPI.CreateCategoriesTable(database)
-	databaseAPI.CreateCategories(database)
-	databaseAPI.CreateCategoriesIcons(database)
+	blobStore, err := storageAPI.NewLocalStore(config.Uploads.Dir)
+	if err != nil {
+		logger.Error("unable to open the uploads directory", "dir", config.Uploads.Dir, "err", err)
+		return 1
+	}
 
 	webAPI.SetDatabase(database)
+	webAPI.SetStore(store)
+	webAPI.SetBlobStore(blobStore)
+	webAPI.SetForumURL(config.Server.URL)
+	webAPI.SetSessionLength(config.Sessions.Length.Duration)
+	webAPI.SetAttachmentLimits(int64(config.Uploads.MaxSize), config.Uploads.MaxFiles)
+	webAPI.SetDigest(config.Digest.Interval.Duration, config.Digest.Posts)
+	webAPI.SetFeeds(config.Feeds.Title, config.Feeds.Entries)
+	webAPI.SetWebhookDelivery(config.Webhooks.Timeout.Duration, config.Webhooks.MaxAttempts, config.Webhooks.RetryDelay.Duration)
+	webAPI.SetBackups(config.Backups.Dir, config.Backups.Keep)
+	public, assets, err := openPublic()
+	if err != nil {
+		logger.Error("unable to load the public files", "dir", config.Server.PublicDir, "err", err)
+		return 1
+	}
+	webAPI.SetPublicFS(public)
+	webAPI.SetAssets(assets)
+	if err := webAPI.LoadTemplates(); err != nil {
+		logger.Error("invalid templates", "dir", config.Server.PublicDir, "err", err)
+		return 1
+	}
+	mailer, err := newMailer()
+	if err != nil {
+		logger.Error("unable to set up emails", "err", err)
+		return 1
+	}
 
-	fs := http.FileServer(http.Dir("public"))
 	router := http.NewServeMux()
-	fmt.Println("Starting server on port 8000")
-
-	router.HandleFunc("/", webAPI.Index)
-	router.HandleFunc("/register", webAPI.Register)
-	router.HandleFunc("/login", webAPI.Login)
-	router.HandleFunc("/post", webAPI.DisplayPost)
-	router.HandleFunc("/filter", webAPI.GetPostsByApi)
-	router.HandleFunc("/newpost", webAPI.NewPost)
-	router.HandleFunc("/api/register", webAPI.RegisterApi)
-	router.HandleFunc("/api/login", webAPI.LoginApi)
-	router.HandleFunc("/api/logout", webAPI.LogoutAPI)
-	router.HandleFunc("/api/createpost", webAPI.CreatePostApi)
-	router.HandleFunc("/api/comments", webAPI.CommentsApi)
-	router.HandleFunc("/api/vote", webAPI.VoteApi)
-
-	router.Handle("/public/", http.StripPrefix("/public/", fs))
-	http.ListenAndServe(":8000", router)
+
+	route(router, "/", webAPI.Index)
+	route(router, "/healthz", webAPI.HealthzApi)
+	route(router, "/readyz", webAPI.ReadyzApi)
+	route(router, "/register", webAPI.Register)
+	route(router, "/login", webAPI.Login)
+	route(router, "/post", webAPI.DisplayPost)
+	route(router, "/filter", webAPI.GetPostsByApi)
+	route(router, "/newpost", webAPI.NewPost)
+	route(router, "/api/register", webAPI.RegisterApi)
+	route(router, "/api/login", webAPI.LoginApi)
+	route(router, "/api/logout", webAPI.LogoutAPI)
+	route(router, "/api/createpost", webAPI.CreatePostApi)
+	route(router, "/api/comments", webAPI.CommentsApi)
+	route(router, "/api/vote", webAPI.VoteApi)
+	route(router, "/api/preview", webAPI.PreviewApi)
+	route(router, "/attachment", webAPI.DisplayAttachment)
+	route(router, "/notifications", webAPI.DisplayNotifications)
+	route(router, "/api/notifications/read", webAPI.ReadNotificationsApi)
+	route(router, "/api/events/post", webAPI.PostEventsApi)
+	route(router, "/api/events/user", webAPI.UserEventsApi)
+	route(router, "/api/subscriptions", webAPI.SubscriptionsApi)
+	route(router, "/feed", webAPI.DisplayFeed)
+	route(router, "/api/bookmarks", webAPI.BookmarksApi)
+	route(router, "/feeds/rss", webAPI.DisplayRssFeed)
+	route(router, "/feeds/atom", webAPI.DisplayAtomFeed)
+	route(router, "/account", webAPI.DisplayAccount)
+	route(router, "/api/account/export", webAPI.ExportApi)
+	route(router, "/api/account/delete", webAPI.DeleteAccountApi)
+	route(router, "/settings", webAPI.DisplaySettings)
+	route(router, "/api/settings", webAPI.SettingsApi)
+	route(router, "/api/settings/email", webAPI.SettingsEmailApi)
+	route(router, "/api/settings/password", webAPI.SettingsPasswordApi)
+	route(router, "/api/reports", webAPI.ReportsApi)
+	route(router, "/admin/reports", webAPI.DisplayReports)
+	route(router, "/api/admin/reports/close", webAPI.CloseReportApi)
+	route(router, "/admin/webhooks", webAPI.DisplayWebhooks)
+	route(router, "/admin/webhooks/deliveries", webAPI.DisplayWebhookDeliveries)
+	route(router, "/api/admin/webhooks", webAPI.WebhooksApi)
+	route(router, "/api/admin/webhooks/retry", webAPI.RetryWebhookDeliveryApi)
+	route(router, "/admin/backups", webAPI.DisplayBackups)
+	route(router, "/api/admin/backups", webAPI.BackupsApi)
+	route(router, "/emails", webAPI.DisplayEmails)
+	route(router, "/api/emails", webAPI.EmailsApi)
+	route(router, "/unsubscribe", webAPI.Unsubscribe)
+
+	router.Handle("/public/", metricsAPI.Instrument("/public/", assets))
+	allowed, _ := metricsAPI.ParseAllowlist(config.Metrics.Allow)
+	router.Handle("/metrics", metricsAPI.Handler(allowed, config.Metrics.Token))
+	metricsAPI.RegisterActiveSessions(func() (int, error) {
+		return databaseAPI.CountActiveSessions(database, time.Now())
+	})
+	server, err := newServer(logAPI.RequestIDs(logAPI.AccessLog(logger, webAPI.SessionUsername, webAPI.Recover(router))))
+	if err != nil {
+		logger.Error("unable to load the certificate", "cert", config.Server.TLSCert, "err", err)
+		return 1
+	}
+	// the event streams never end by themselves, they are closed for the shutdown to complete
+	server.RegisterOnShutdown(webAPI.CloseEvents)
+
+	background := newJobs()
+	if mailer != nil {
+		webAPI.SetMailer(mailer, config.Mail.From)
+		background.start(webAPI.RunDigestScheduler)
+		background.start(webAPI.RunReplyEmailSender)
+	}
+	background.start(webAPI.RunWebhookDispatcher)
+	if config.Server.Dev {
+		background.start(webAPI.WatchTemplates)
+	}
+	if interval := config.Backups.Interval.Duration; interval > 0 {
+		background.start(func(ctx context.Context) {
+			webAPI.RunBackupScheduler(ctx, interval)
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger.Info("starting server", "addr", config.Server.Addr, "tls", server.TLSConfig != nil, "dev", config.Server.Dev)
+	code := 0
+	if err := serve(ctx, server); err != nil {
+		logger.Error("server failed", "err", err)
+		code = 1
+	}
+	// the jobs stop after the last requests, then nothing uses the database anymore
+	background.stop()
+	if err := database.Close(); err != nil {
+		logger.Error("unable to close the database", "err", err)
+		code = 1
+	}
+	if code == 0 {
+		logger.Info("server stopped")
+	}
+	return code
+}
+
+// route registers a handler on the router, instrumented for the metrics under its pattern
+func route(router *http.ServeMux, pattern string, handler http.HandlerFunc) {
+	router.Handle(pattern, metricsAPI.Instrument(pattern, handler))
+}
+
+// openDatabase opens the database of the configuration, creating the SQLite database if it doesn't exist, creates its
+// missing tables and migrates it
+func openDatabase() error {
+	if config.Database.Driver != "postgres" {
+		// check if DB exists
+		_, err := os.Stat(config.Database.Path)
+
+		// create DB if not exists
+		if os.IsNotExist(err) {
+			var file, err = os.Create(config.Database.Path)
+			if err != nil {
+				return err
+			}
+			file.Close()
+		}
+	}
+	if err := connectDatabase(); err != nil {
+		return err
+	}
+	return initDatabase()
+}
+
+// connectDatabase opens the database of the configuration without changing it
+func connectDatabase() error {
+	var err error
+	if config.Database.Driver == "postgres" {
+		database, err = sql.Open(databaseAPI.ObservedPostgresDriver, config.Database.URL)
+	} else {
+		database, err = sql.Open(databaseAPI.ObservedDriver, config.Database.Path)
+	}
+	if err != nil {
+		return err
+	}
+	store = databaseAPI.NewSQLStore(database)
+	return nil
+}
+
+// initDatabase creates the missing tables of the opened database and migrates it
+func initDatabase() error {
+	if err := databaseAPI.CheckSchemaVersion(database); err != nil {
+		return err
+	}
+	if err := store.CreateTables(); err != nil {
+		return err
+	}
+	if err := databaseAPI.CreateTables(database); err != nil {
+		return err
+	}
+	return databaseAPI.Migrate(database)
+}
+
+// databaseName returns the database of the configuration as shown in the messages, without the URL of PostgreSQL
+// which can hold a password
+func databaseName() string {
+	if config.Database.Driver == "postgres" {
+		return "PostgreSQL"
+	}
+	return config.Database.Path
+}
+
+// newMailer returns the mailer of the configuration: SMTP when mail.smtp_addr is set, .eml files written in mail.dir
+// when it is set, or nil to disable emails
+func newMailer() (mailAPI.Mailer, error) {
+	if config.Mail.SMTPAddr != "" {
+		return mailAPI.SMTPMailer{Addr: config.Mail.SMTPAddr, Username: config.Mail.SMTPUsername, Password: config.Mail.SMTPPassword}, nil
+	}
+	if config.Mail.Dir != "" {
+		return mailAPI.NewFileMailer(config.Mail.Dir)
+	}
+	return nil, nil
 }
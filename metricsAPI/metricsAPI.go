@@ -0,0 +1,158 @@
+package metricsAPI
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds the metrics of the forum, with the metrics of the Go runtime and of the process
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "forum_http_requests_total",
+		Help: "HTTP requests served, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "forum_http_request_duration_seconds",
+		Help:    "Time to serve HTTP requests, by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// QueryDuration is the time of the database queries, by operation such as select or insert
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "forum_database_query_duration_seconds",
+		Help:    "Time of the database queries until their first row, by operation.",
+		Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, 1},
+	}, []string{"operation"})
+
+	// BcryptDuration is the time to hash a password or to compare it to a hash
+	BcryptDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "forum_bcrypt_duration_seconds",
+		Help:    "Time of the bcrypt hashes and comparisons of passwords.",
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation"})
+
+	// LoginFailures counts the failed logins, by reason
+	LoginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "forum_login_failures_total",
+		Help: "Failed logins, by reason.",
+	}, []string{"reason"})
+
+	// PostsCreated counts the posts created
+	PostsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "forum_posts_created_total",
+		Help: "Posts created.",
+	})
+
+	// CommentsCreated counts the comments created
+	CommentsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "forum_comments_created_total",
+		Help: "Comments created.",
+	})
+
+	// VotesCast counts the votes, by vote: up, down or removed
+	VotesCast = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "forum_votes_total",
+		Help: "Votes cast on posts, by vote: up, down or removed.",
+	}, []string{"vote"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, QueryDuration, BcryptDuration, LoginFailures, PostsCreated, CommentsCreated, VotesCast,
+	)
+}
+
+// RegisterActiveSessions adds the gauge of the sessions that haven't expired, counted by count when scraped
+func RegisterActiveSessions(count func() (int, error)) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "forum_active_sessions",
+		Help: "Sessions that haven't expired.",
+	}, func() float64 {
+		n, err := count()
+		if err != nil {
+			return -1
+		}
+		return float64(n)
+	}))
+}
+
+// Instrument counts the requests served by handler and observes their duration under the route pattern
+func Instrument(pattern string, handler http.Handler) http.Handler {
+	route := prometheus.Labels{"route": pattern}
+	return promhttp.InstrumentHandlerCounter(httpRequests.MustCurryWith(route),
+		promhttp.InstrumentHandlerDuration(httpDuration.MustCurryWith(route), handler))
+}
+
+// Handler serves the metrics to the clients whose address is in allowed, or which send the token as a bearer token
+// if it isn't empty
+func Handler(allowed []*net.IPNet, token string) http.Handler {
+	metrics := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedAddress(allowed, r.RemoteAddr) && !validToken(token, r.Header.Get("Authorization")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		metrics.ServeHTTP(w, r)
+	})
+}
+
+// allowedAddress returns true if the IP of the remote address is in one of the allowed networks
+func allowedAddress(allowed []*net.IPNet, remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	for _, network := range allowed {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// validToken returns true if the Authorization header holds the token as a bearer token
+func validToken(token string, authorization string) bool {
+	given, ok := strings.CutPrefix(authorization, "Bearer ")
+	return token != "" && ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// ParseAllowlist parses a list of IP addresses and CIDR networks separated by commas
+func ParseAllowlist(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, errors.New("invalid address " + entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, errors.New("invalid network " + entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
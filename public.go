@@ -0,0 +1,31 @@
+package main
+
+import (
+	"FORUM-GO/assetsAPI"
+	"embed"
+	"io/fs"
+	"os"
+)
+
+// embeddedPublic holds the templates, styles and scripts, so that the binary serves them from any directory
+//
+//go:embed public
+var embeddedPublic embed.FS
+
+// templateDirs are the directories of the public files holding templates, which aren't served as assets
+var templateDirs = []string{"HTML", "MAIL"}
+
+// openPublic returns the templates, styles and scripts, and their assets: the files of server.public_dir, served as
+// they are on the disk, or else the files embedded in the binary, served under hashed names and precompressed
+func openPublic() (fs.FS, *assetsAPI.Assets, error) {
+	if config.Server.PublicDir != "" {
+		public := os.DirFS(config.Server.PublicDir)
+		return public, assetsAPI.Live(public, "/public/", templateDirs...), nil
+	}
+	public, err := fs.Sub(embeddedPublic, "public")
+	if err != nil {
+		return nil, nil, err
+	}
+	assets, err := assetsAPI.Load(public, "/public/", templateDirs...)
+	return public, assets, err
+}
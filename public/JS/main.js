@@ -29,7 +29,10 @@ function upvote(Id) {
         "mode": "cors",
         "credentials": "include"
     }).then(() => {
-        location.reload();
+        // the score is updated by the post events when they are streamed
+        if (!postEvents) {
+            location.reload();
+        }
     });
 }
 
@@ -57,6 +60,128 @@ function downvote(Id) {
         "mode": "cors",
         "credentials": "include"
     }).then(() => {
-        location.reload();
+        // the score is updated by the post events when they are streamed
+        if (!postEvents) {
+            location.reload();
+        }
     });
-}
\ No newline at end of file
+}
+//Preview
+function preview(inputId, previewId) {
+    var previewArea = document.getElementById(previewId);
+    fetch("/api/preview", {
+        "headers": {
+            "accept": "application/json",
+            "content-type": "application/x-www-form-urlencoded"
+        },
+        "body": "content=" + encodeURIComponent(document.getElementById(inputId).value),
+        "method": "POST",
+        "credentials": "include"
+    }).then((response) => {
+        // the errors come as JSON, the preview as HTML
+        if (!response.ok) {
+            return response.json().then((error) => {
+                previewArea.textContent = error.message;
+                previewArea.classList.remove("hide");
+            });
+        }
+        return response.text().then((html) => {
+            previewArea.innerHTML = html;
+            previewArea.classList.remove("hide");
+        });
+    });
+}
+
+//Reply to a comment
+function replyTo(commentId, username) {
+    document.getElementById("parentId").value = commentId;
+    var replying = document.getElementById("replying");
+    replying.textContent = "Replying to " + username;
+    replying.classList.remove("hide");
+    var textArea = document.getElementById("commentTextArea");
+    if (/^[\w.-]+$/.test(username) && textArea.value.indexOf("@" + username) === -1) {
+        textArea.value = "@" + username + " " + textArea.value;
+    }
+    showComment();
+    textArea.focus();
+}
+
+//Live updates
+var postEvents = null;
+
+// listen opens an event stream, and opens it again later if the server refused it
+function listen(url, listeners, retryDelay) {
+    if (!window.EventSource) {
+        return null;
+    }
+    var source = new EventSource(url);
+    for (var type in listeners) {
+        source.addEventListener(type, listeners[type]);
+    }
+    source.onopen = function () {
+        retryDelay = 5000;
+    };
+    source.onerror = function () {
+        // the browser reconnects by itself unless the stream was refused, when too many clients are connected
+        if (source.readyState === EventSource.CLOSED) {
+            setTimeout(function () {
+                listen(url, listeners, Math.min(retryDelay * 2, 300000));
+            }, retryDelay);
+        }
+    };
+    return source;
+}
+
+function addComment(event) {
+    var comment = JSON.parse(event.data);
+    if (document.getElementById("comment-" + comment.id)) {
+        return;
+    }
+    var container = document.createElement("div");
+    container.className = "comments-container";
+    container.id = "comment-" + comment.id;
+    container.innerHTML = '<div class="body"><div class="authors"><div class="username"><a></a></div>' +
+        '<img src="https://cdn-icons-png.flaticon.com/512/149/149071.png" alt=""></div><br>' +
+        '<div class="content"><div class="post-content"><div class="markdown"></div></div><br><hr>' +
+        '<span class="created-at"></span></div></div>';
+    container.querySelector(".username a").textContent = comment.username;
+    // the html is rendered and sanitized by the server
+    container.querySelector(".markdown").innerHTML = comment.html;
+    // the comment has just been posted, its time is shown in the time zone of the browser
+    var createdAt = container.querySelector(".created-at");
+    createdAt.textContent = "just now";
+    createdAt.title = new Date(comment.createdAt).toLocaleString();
+    document.getElementById("comments").appendChild(container);
+}
+
+function updateScore(event) {
+    var score = JSON.parse(event.data);
+    document.getElementById("upvotes").textContent = score.upVotes;
+    document.getElementById("downvotes").textContent = score.downVotes;
+}
+
+function updateBell(event) {
+    var notification = JSON.parse(event.data);
+    var bell = document.querySelector(".header a.bell");
+    var badge = bell.querySelector(".badge");
+    if (!badge) {
+        badge = document.createElement("span");
+        badge.className = "badge";
+        bell.appendChild(badge);
+    }
+    badge.textContent = notification.unread;
+    bell.title = notification.message;
+}
+
+(function () {
+    var post = document.getElementById("post");
+    if (post && post.dataset.postId) {
+        postEvents = listen("/api/events/post?id=" + post.dataset.postId, {
+            "comment": addComment,
+            "score": updateScore
+        }, 5000);
+    }
+    if (document.querySelector(".header a.bell")) {
+        listen("/api/events/user", {"notification": updateBell}, 5000);
+    }
+})();
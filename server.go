@@ -0,0 +1,183 @@
+package main
+
+import (
+	"FORUM-GO/webAPI"
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// certificateCheckInterval is the interval at which the certificate files are checked for changes
+const certificateCheckInterval = 10 * time.Second
+
+// newServer returns the HTTP server of the forum, with the timeouts of the configuration and its certificate if it
+// serves HTTPS
+func newServer(handler http.Handler) (*http.Server, error) {
+	server := &http.Server{
+		Addr:              config.Server.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.Server.ReadHeaderTimeout.Duration,
+		ReadTimeout:       config.Server.ReadTimeout.Duration,
+		WriteTimeout:      config.Server.WriteTimeout.Duration,
+		IdleTimeout:       config.Server.IdleTimeout.Duration,
+		MaxHeaderBytes:    config.Server.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	if config.Server.TLSCert != "" {
+		certificate, err := newCertificateReloader(config.Server.TLSCert, config.Server.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: certificate.GetCertificate}
+	}
+	return server, nil
+}
+
+// serve runs the server until ctx is canceled, then shuts it down gracefully: it fails the readiness check for the
+// drain delay, stops accepting connections and waits for the requests in progress for at most the shutdown timeout
+func serve(ctx context.Context, server *http.Server) error {
+	errs := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			errs <- server.ListenAndServeTLS("", "")
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	// the orchestrator stops sending traffic once /readyz fails, the requests sent meanwhile are still served
+	webAPI.SetShuttingDown()
+	if delay := config.Server.DrainDelay.Duration; delay > 0 {
+		logger.Info("draining the server", "delay", delay.String())
+		time.Sleep(delay)
+	}
+	logger.Info("shutting down the server", "timeout", config.Server.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Server.ShutdownTimeout.Duration)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// jobs runs the background jobs of the server, which stop when their context is canceled
+type jobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wait   sync.WaitGroup
+}
+
+func newJobs() *jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobs{ctx: ctx, cancel: cancel}
+}
+
+// start runs job in the background
+func (j *jobs) start(job func(ctx context.Context)) {
+	j.wait.Add(1)
+	go func() {
+		defer j.wait.Done()
+		job(j.ctx)
+	}()
+}
+
+// stop stops the jobs and waits for them to return
+func (j *jobs) stop() {
+	j.cancel()
+	j.wait.Wait()
+}
+
+// certificateReloader serves the certificate of a key pair, loaded again when its files change or on SIGHUP, so that
+// a renewed certificate is served without restarting the server
+type certificateReloader struct {
+	certFile    string
+	keyFile     string
+	lock        sync.Mutex
+	certificate *tls.Certificate
+	modTime     time.Time
+	checked     time.Time
+}
+
+// newCertificateReloader loads the key pair and returns its reloader
+func newCertificateReloader(certFile string, keyFile string) (*certificateReloader, error) {
+	c := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	hangUp := make(chan os.Signal, 1)
+	signal.Notify(hangUp, syscall.SIGHUP)
+	go func() {
+		for range hangUp {
+			c.lock.Lock()
+			c.reload()
+			c.lock.Unlock()
+		}
+	}()
+	return c, nil
+}
+
+// GetCertificate returns the certificate, loading it again if its files changed since the last check
+func (c *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if time.Since(c.checked) >= certificateCheckInterval {
+		c.checked = time.Now()
+		if modTime, err := c.filesModTime(); err == nil && !modTime.Equal(c.modTime) {
+			c.reload()
+		}
+	}
+	return c.certificate, nil
+}
+
+// reload loads the key pair again, keeping the previous certificate if it is invalid, the lock must be held
+func (c *certificateReloader) reload() {
+	if err := c.load(); err != nil {
+		logger.Error("unable to reload the certificate, the previous one is kept", "cert", c.certFile, "err", err)
+		return
+	}
+	logger.Info("certificate reloaded", "cert", c.certFile)
+}
+
+// load loads the key pair
+func (c *certificateReloader) load() error {
+	modTime, err := c.filesModTime()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.certificate = &certificate
+	c.modTime = modTime
+	return nil
+}
+
+// filesModTime returns the latest modification time of the certificate and key files
+func (c *certificateReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
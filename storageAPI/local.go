@@ -0,0 +1,78 @@
+package storageAPI
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore stores blobs as files in a directory
+type LocalStore struct {
+	Dir string
+}
+
+// NewLocalStore creates the directory if needed and returns a store using it
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{Dir: dir}, nil
+}
+
+// path returns the file path of a key, blobs are spread in sub directories named after the key prefix
+func (store *LocalStore) path(key string) string {
+	if len(key) < 2 {
+		return filepath.Join(store.Dir, key)
+	}
+	return filepath.Join(store.Dir, key[:2], key)
+}
+
+// Put writes the content to a temporary file and renames it, so readers never see a partial blob
+func (store *LocalStore) Put(key string, content io.Reader) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	path := store.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Open opens the file of a key
+func (store *LocalStore) Open(key string) (io.ReadSeekCloser, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(store.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+// Delete removes the file of a key
+func (store *LocalStore) Delete(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(store.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,33 @@
+package storageAPI
+
+import (
+	"errors"
+	"io"
+	"regexp"
+)
+
+// BlobStore stores the uploaded files, the local directory is the default implementation
+type BlobStore interface {
+	// Put stores the content under the given key, replacing any previous content
+	Put(key string, content io.Reader) error
+	// Open returns the content stored under the given key
+	Open(key string) (io.ReadSeekCloser, error)
+	// Delete removes the content stored under the given key
+	Delete(key string) error
+}
+
+// ErrNotFound is returned when no content is stored under a key
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned when a key contains characters other than lowercase letters, digits and dashes
+var ErrInvalidKey = errors.New("invalid blob key")
+
+var validKey = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// checkKey returns an error if the key can't be used by a store
+func checkKey(key string) error {
+	if !validKey.MatchString(key) {
+		return ErrInvalidKey
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"FORUM-GO/logAPI"
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	deleteAnonymize = "anonymize"
+	deletePurge     = "purge"
+)
+
+type AccountPage struct {
+	User    User
+	Message string
+}
+
+type ExportProfile struct {
+	Username           string                   `json:"username"`
+	Email              string                   `json:"email"`
+	Admin              bool                     `json:"admin"`
+	DigestEmails       bool                     `json:"digestEmails"`
+	ReplyEmails        bool                     `json:"replyEmails"`
+	FollowedUsers      []string                 `json:"followedUsers"`
+	FollowedThreads    []string                 `json:"followedThreads"`
+	FollowedCategories []string                 `json:"followedCategories"`
+	Bookmarks          []databaseAPI.Bookmark   `json:"bookmarks"`
+	Settings           databaseAPI.UserSettings `json:"settings"`
+}
+
+type ExportPost struct {
+	Id          int                `json:"id"`
+	Title       string             `json:"title"`
+	Categories  []string           `json:"categories"`
+	Content     string             `json:"content"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpVotes     int                `json:"upVotes"`
+	DownVotes   int                `json:"downVotes"`
+	Attachments []ExportAttachment `json:"attachments"`
+}
+
+type ExportAttachment struct {
+	Filename string `json:"filename"`
+	MimeType string `json:"mimeType"`
+	Size     int64  `json:"size"`
+	Path     string `json:"path"`
+}
+
+type ExportComment struct {
+	Id        int       `json:"id"`
+	PostId    int       `json:"postId"`
+	ParentId  int       `json:"parentId"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ExportVote struct {
+	PostId    int    `json:"postId"`
+	PostTitle string `json:"postTitle"`
+	Vote      int    `json:"vote"`
+}
+
+// DisplayAccount displays the page to download the data of the user and to delete their account
+func DisplayAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	payload := AccountPage{User: getCurrentUser(r)}
+	if r.URL.Query().Get("err") == "invalid_password" {
+		payload.Message = "Invalid password"
+	}
+	renderTemplate(w, r, "account.html", payload)
+}
+
+// ExportApi sends a ZIP archive of the data of the user: their profile, posts with their attachments, comments and votes
+func ExportApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	profile, err := exportProfile(username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	posts, attachments, err := exportPosts(username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	comments, err := databaseAPI.GetUserComments(database, username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	votes, err := databaseAPI.GetUserVotes(database, username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	exportedComments := []ExportComment{}
+	for _, comment := range comments {
+		exportedComments = append(exportedComments, ExportComment{Id: comment.Id, PostId: comment.PostId, ParentId: comment.ParentId, Content: comment.Content, CreatedAt: comment.CreatedAt})
+	}
+	exportedVotes := []ExportVote{}
+	for _, vote := range votes {
+		exportedVotes = append(exportedVotes, ExportVote{PostId: vote.PostId, PostTitle: vote.PostTitle, Vote: vote.Vote})
+	}
+	now := time.Now()
+	filename := "forum-" + username + "-" + now.Format("2006-01-02") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	// with the attachments, the archive can take longer than the write timeout of the server
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	archive := zip.NewWriter(w)
+	// the response has started, an error can only cut the archive short
+	files := []struct {
+		name string
+		data interface{}
+	}{{"profile.json", profile}, {"posts.json", posts}, {"comments.json", exportedComments}, {"votes.json", exportedVotes}}
+	for _, f := range files {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(f.data); err != nil {
+			return
+		}
+	}
+	for path, attachment := range attachments {
+		if err := exportBlob(archive, path, attachment, now); err != nil {
+			logger.ErrorContext(r.Context(), "export of an attachment failed", "attachment_id", attachment.Id, "err", err)
+			return
+		}
+	}
+	archive.Close()
+	logAPI.Audit(r.Context(), logger, logAPI.DataExported{Username: username})
+}
+
+// exportProfile returns the account, the preferences, the settings, the subscriptions and the bookmarks of a user
+func exportProfile(username string) (ExportProfile, error) {
+	profile := ExportProfile{Username: username}
+	var err error
+	if profile.Email, err = store.GetUserEmail(username); err != nil {
+		return profile, err
+	}
+	if profile.Admin, err = store.IsAdmin(username); err != nil {
+		return profile, err
+	}
+	preferences, err := databaseAPI.GetEmailPreferences(database, username)
+	if err != nil {
+		return profile, err
+	}
+	profile.DigestEmails, profile.ReplyEmails = preferences.Digest, preferences.Replies
+	if profile.FollowedUsers, err = databaseAPI.GetFollowed(database, username, subscriptionUser); err != nil {
+		return profile, err
+	}
+	if profile.FollowedThreads, err = databaseAPI.GetFollowed(database, username, subscriptionThread); err != nil {
+		return profile, err
+	}
+	if profile.FollowedCategories, err = databaseAPI.GetFollowed(database, username, subscriptionCategory); err != nil {
+		return profile, err
+	}
+	if profile.Bookmarks, err = databaseAPI.GetBookmarks(database, username, ""); err != nil {
+		return profile, err
+	}
+	if profile.Settings, err = databaseAPI.GetUserSettings(database, username); err != nil {
+		return profile, err
+	}
+	// empty lists are exported as [] rather than null
+	for _, list := range []*[]string{&profile.FollowedUsers, &profile.FollowedThreads, &profile.FollowedCategories, &profile.Settings.MutedNotifications} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	if profile.Bookmarks == nil {
+		profile.Bookmarks = []databaseAPI.Bookmark{}
+	}
+	return profile, nil
+}
+
+// exportPosts returns the posts of a user, and their attachments by their path in the archive
+func exportPosts(username string) ([]ExportPost, map[string]databaseAPI.Attachment, error) {
+	posts := []ExportPost{}
+	attachments := map[string]databaseAPI.Attachment{}
+	userPosts, err := store.GetPostsByUser(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, post := range userPosts {
+		exported := ExportPost{
+			Id:          post.Id,
+			Title:       post.Title,
+			Categories:  post.Categories,
+			Content:     post.Content,
+			CreatedAt:   post.CreatedAt,
+			UpVotes:     post.UpVotes,
+			DownVotes:   post.DownVotes,
+			Attachments: []ExportAttachment{},
+		}
+		postAttachments, err := databaseAPI.GetAttachments(database, strconv.Itoa(post.Id))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, attachment := range postAttachments {
+			path := "attachments/" + strconv.Itoa(attachment.Id) + "-" + attachment.Filename
+			attachments[path] = attachment
+			exported.Attachments = append(exported.Attachments, ExportAttachment{Filename: attachment.Filename, MimeType: attachment.MimeType, Size: attachment.Size, Path: path})
+		}
+		posts = append(posts, exported)
+	}
+	return posts, attachments, nil
+}
+
+// exportBlob copies the file of an attachment in the archive
+func exportBlob(archive *zip.Writer, path string, attachment databaseAPI.Attachment, modified time.Time) error {
+	blob, err := blobStore.Open(attachment.BlobKey)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	// images and PDFs are already compressed
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: path, Method: zip.Store, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, blob)
+	return err
+}
+
+// DeleteAccountApi deletes the account of the user after checking their password. With mode=purge, their posts and
+// comments are deleted, with mode=anonymize they are kept without their name.
+func DeleteAccountApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	mode := r.FormValue("mode")
+	if mode != deleteAnonymize && mode != deletePurge {
+		renderError(w, r, http.StatusBadRequest, "Invalid mode")
+		return
+	}
+	valid, err := checkPassword(username, r.FormValue("password"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !valid {
+		http.Redirect(w, r, "/account?err=invalid_password", http.StatusFound)
+		return
+	}
+	unusedBlobs, err := databaseAPI.DeleteUser(database, username, mode == deletePurge)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "account deletion failed", "username", username, "err", err)
+		writeStatus(w, r, http.StatusInternalServerError)
+		return
+	}
+	for _, key := range unusedBlobs {
+		if err := blobStore.Delete(key); err != nil {
+			logger.ErrorContext(r.Context(), "blob deletion failed", "key", key, "err", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: "SESSION", Value: "", Path: "/", MaxAge: -1})
+	logAPI.Audit(r.Context(), logger, logAPI.AccountDeleted{Username: username, Mode: mode})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
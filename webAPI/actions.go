@@ -2,7 +2,9 @@ package webAPI
 
 import (
 	"FORUM-GO/databaseAPI"
-	"fmt"
+	"FORUM-GO/logAPI"
+	"FORUM-GO/metricsAPI"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -17,35 +19,77 @@ type Vote struct {
 // CreatePostApi creates a post
 func CreatePostApi(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
-	}
-	if err := r.ParseForm(); err != nil {
-		fmt.Fprintf(w, "ParseForm() err: %v", err)
+		writeStatus(w, r, http.StatusMethodNotAllowed)
 		return
 	}
+	// the session is checked before the body is read, so that only users can upload files
 	if !isLoggedIn(r) {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
-	cookie, _ := r.Cookie("SESSION")
-	username := databaseAPI.GetUser(database, cookie.Value)
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxAttachments)*maxAttachmentSize+multipartMaxMemory)
+	if err := r.ParseMultipartForm(multipartMaxMemory); err != nil && err != http.ErrNotMultipart {
+		writeFormError(w, r, err)
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	uploads, err := readUploads(r)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid attachment : "+err.Error())
+		return
+	}
 	title := r.FormValue("title")
 	content := r.FormValue("content")
 	categories := r.Form["categories[]"]
-	validCategories := databaseAPI.GetCategories(database)
+	validCategories, err := store.GetCategories()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 	for _, category := range categories {
 		// if string not in array, return error
 		if !inArray(category, validCategories) {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("Invalid category : " + category))
+			renderError(w, r, http.StatusBadRequest, "Invalid category : "+category)
 			return
 		}
 	}
 	stringCategories := strings.Join(categories, ",")
+	// the blobs are stored before the post, and removed if the post and its attachments can't be created
+	attachments, err := storeUploads(uploads, username)
+	if err != nil {
+		removeUploads(r, attachments)
+		logger.ErrorContext(r.Context(), "storing attachments failed", "err", err)
+		writeStatus(w, r, http.StatusInternalServerError)
+		return
+	}
 	now := time.Now()
-	databaseAPI.CreatePost(database, username, title, stringCategories, content, now)
-	fmt.Println("Post created by " + username + " with title " + title + " at " + now.Format("2006-01-02 15:04:05"))
+	postId, err := store.CreatePost(username, title, stringCategories, content, attachments, now)
+	if err != nil {
+		removeUploads(r, attachments)
+		writeError(w, r, err)
+		return
+	}
+	metricsAPI.PostsCreated.Inc()
+	logAPI.Audit(r.Context(), logger, logAPI.PostCreated{Username: username, PostId: postId, Title: title})
+	post := databaseAPI.Post{Id: postId, Username: username, Title: title}
+	notified := map[string]bool{username: true}
+	notifyMentions(content, username, post, 0, notified)
+	notifyFollowers(post, notified)
+	fireWebhooks(eventPostCreated, username, postId, categories, PostPayload{
+		Id:         postId,
+		Username:   username,
+		Title:      title,
+		Categories: categories,
+		Content:    content,
+		URL:        postURL(postId, 0),
+	})
 	http.Redirect(w, r, "/filter?by=myposts", http.StatusFound)
 	return
 }
@@ -53,25 +97,61 @@ func CreatePostApi(w http.ResponseWriter, r *http.Request) {
 // CommentsApi creates a comment
 func CommentsApi(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeStatus(w, r, http.StatusMethodNotAllowed)
 		return
 	}
 	if err := r.ParseForm(); err != nil {
-		fmt.Fprintf(w, "ParseForm() err: %v", err)
+		writeFormError(w, r, err)
 		return
 	}
 	if !isLoggedIn(r) {
 		http.Redirect(w, r, "/login", http.StatusFound)
 		return
 	}
-	cookie, _ := r.Cookie("SESSION")
-	username := databaseAPI.GetUser(database, cookie.Value)
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 	postId := r.FormValue("postId")
 	content := r.FormValue("content")
 	now := time.Now()
 	postIdInt, _ := strconv.Atoi(postId)
-	databaseAPI.AddComment(database, username, postIdInt, content, now)
-	fmt.Println("Comment created by " + username + " on post " + postId + " at " + now.Format("2006-01-02 15:04:05"))
+	post, err := store.GetPost(postId)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	parentId, _ := strconv.Atoi(r.FormValue("parentId"))
+	if parentId != 0 {
+		parent, err := store.GetComment(parentId)
+		if err != nil && !errors.Is(err, databaseAPI.ErrNotFound) {
+			writeError(w, r, err)
+			return
+		}
+		if err != nil || parent.PostId != postIdInt {
+			renderError(w, r, http.StatusBadRequest, "Invalid parent comment")
+			return
+		}
+	}
+	commentId, err := store.AddComment(username, postIdInt, parentId, content, now)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	metricsAPI.CommentsCreated.Inc()
+	logAPI.Audit(r.Context(), logger, logAPI.CommentCreated{Username: username, PostId: postIdInt, CommentId: commentId})
+	comment := databaseAPI.Comment{Id: commentId, PostId: postIdInt, ParentId: parentId, Username: username, Content: content, CreatedAt: now.UTC().Truncate(time.Second)}
+	publishComment(comment)
+	notifyComment(post, comment)
+	fireWebhooks(eventCommentCreated, comment.Username, comment.PostId, post.Categories, CommentPayload{
+		Id:       comment.Id,
+		PostId:   comment.PostId,
+		ParentId: comment.ParentId,
+		Username: comment.Username,
+		Content:  comment.Content,
+		URL:      postURL(comment.PostId, comment.Id),
+	})
 	http.Redirect(w, r, "/post?id="+postId, http.StatusFound)
 }
 
@@ -79,74 +159,108 @@ func CommentsApi(w http.ResponseWriter, r *http.Request) {
 func VoteApi(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		if !isLoggedIn(r) {
-			w.WriteHeader(http.StatusUnauthorized)
+			writeStatus(w, r, http.StatusUnauthorized)
 			return
 		}
 		if err := r.ParseForm(); err != nil {
-			fmt.Fprintf(w, "ParseForm() err: %v", err)
+			writeFormError(w, r, err)
+			return
+		}
+		username, err := sessionUser(r)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
-		cookie, _ := r.Cookie("SESSION")
-		username := databaseAPI.GetUser(database, cookie.Value)
 		postId := r.FormValue("postId")
-		postIdInt, _ := strconv.Atoi(postId)
 		vote := r.FormValue("vote")
 		voteInt, _ := strconv.Atoi(vote)
-		now := time.Now().Format("2006-01-02 15:04:05")
-		if voteInt == 1 {
-			if databaseAPI.HasUpvoted(database, username, postIdInt) {
-				databaseAPI.RemoveVote(database, postIdInt, username)
-				databaseAPI.DecreaseUpvotes(database, postIdInt)
-				fmt.Println("Removed upvote from " + username + " on post " + postId + " at " + now)
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("Vote removed"))
-				return
-			}
-			if databaseAPI.HasDownvoted(database, username, postIdInt) {
-				databaseAPI.DecreaseDownvotes(database, postIdInt)
-				databaseAPI.IncreaseUpvotes(database, postIdInt)
-				databaseAPI.UpdateVote(database, postIdInt, username, 1)
-				fmt.Println(username + " upvoted" + " on post " + postId + " at " + now)
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("Upvote added"))
-				return
-			}
-			databaseAPI.IncreaseUpvotes(database, postIdInt)
-			databaseAPI.AddVote(database, postIdInt, username, 1)
-			fmt.Println(username + " upvoted" + " on post " + postId + " at " + now)
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("Upvote added"))
+		if voteInt != 1 && voteInt != -1 {
+			renderError(w, r, http.StatusBadRequest, "Invalid vote")
 			return
 		}
-		if voteInt == -1 {
-			if databaseAPI.HasDownvoted(database, username, postIdInt) {
-				databaseAPI.RemoveVote(database, postIdInt, username)
-				databaseAPI.DecreaseDownvotes(database, postIdInt)
-				fmt.Println("Removed downvote from " + username + " on post " + postId + " at " + now)
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("Vote removed"))
-				return
-			}
-			if databaseAPI.HasUpvoted(database, username, postIdInt) {
-				databaseAPI.DecreaseUpvotes(database, postIdInt)
-				databaseAPI.IncreaseDownvotes(database, postIdInt)
-				databaseAPI.UpdateVote(database, postIdInt, username, -1)
-				fmt.Println(username + " downvoted" + " on post " + postId + " at " + now)
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("Downvote added"))
-				return
-			}
-			databaseAPI.IncreaseDownvotes(database, postIdInt)
-			databaseAPI.AddVote(database, postIdInt, username, -1)
-			fmt.Println(username + " downvoted" + " on post " + postId + " at " + now)
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("Downvote added"))
+		post, err := store.GetPost(postId)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid vote"))
+		previous, err := currentVote(username, post.Id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		// voting again the same way removes the vote
+		if previous == voteInt {
+			voteInt = 0
+		}
+		if err := changeVote(post.Id, username, previous, voteInt); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		message := "Vote removed"
+		switch voteInt {
+		case 0:
+			metricsAPI.VotesCast.WithLabelValues("removed").Inc()
+		case 1:
+			notifyVoteMilestone(post.Id)
+			metricsAPI.VotesCast.WithLabelValues("up").Inc()
+			message = "Upvote added"
+		case -1:
+			metricsAPI.VotesCast.WithLabelValues("down").Inc()
+			message = "Downvote added"
+		}
+		logAPI.Audit(r.Context(), logger, logAPI.VoteCast{Username: username, PostId: post.Id, Vote: voteInt})
+		publishScore(post.Id)
+		fireVoteWebhooks(post.Id, username, voteInt)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(message))
 		return
 	}
-	w.WriteHeader(http.StatusMethodNotAllowed)
+	writeStatus(w, r, http.StatusMethodNotAllowed)
 	return
 }
+
+// currentVote returns the vote of a user on a post: 1, -1 or 0 if they haven't voted
+func currentVote(username string, postId int) (int, error) {
+	upvoted, err := store.HasUpvoted(username, postId)
+	if err != nil || upvoted {
+		return 1, err
+	}
+	downvoted, err := store.HasDownvoted(username, postId)
+	if err != nil || downvoted {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// changeVote replaces the previous vote of a user on a post by a new one, 0 meaning no vote, and updates the votes
+// counts of the post
+func changeVote(postId int, username string, previous int, vote int) error {
+	var err error
+	switch {
+	case previous == 0:
+		err = store.AddVote(postId, username, vote)
+	case vote == 0:
+		err = store.RemoveVote(postId, username)
+	default:
+		err = store.UpdateVote(postId, username, vote)
+	}
+	if err != nil {
+		return err
+	}
+	switch previous {
+	case 1:
+		err = store.DecreaseUpvotes(postId)
+	case -1:
+		err = store.DecreaseDownvotes(postId)
+	}
+	if err != nil {
+		return err
+	}
+	switch vote {
+	case 1:
+		return store.IncreaseUpvotes(postId)
+	case -1:
+		return store.IncreaseDownvotes(postId)
+	}
+	return nil
+}
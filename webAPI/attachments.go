@@ -0,0 +1,259 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"FORUM-GO/storageAPI"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// maxImagePixels bounds the memory used to decode an image for its thumbnail, about 4 bytes a pixel
+	maxImagePixels     = 24 << 20
+	thumbnailSize      = 320
+	attachmentsField   = "attachments"
+	attachmentMaxAge   = 24 * time.Hour
+	multipartMaxMemory = 8 << 20
+)
+
+// maxAttachmentSize is the maximum size of an attachment in bytes, and maxAttachments the maximum number of attachments
+// of a post
+var (
+	maxAttachmentSize int64 = 5 << 20
+	maxAttachments          = 5
+)
+
+// SetAttachmentLimits sets the maximum size of an attachment in bytes and the maximum number of attachments of a post
+func SetAttachmentLimits(maxSize int64, maxFiles int) {
+	maxAttachmentSize = maxSize
+	maxAttachments = maxFiles
+}
+
+// allowedMimeTypes are the content types accepted for attachments, as sniffed from the file content
+var allowedMimeTypes = map[string]bool{
+	"image/png":                 true,
+	"image/jpeg":                true,
+	"image/gif":                 true,
+	"image/webp":                true,
+	"application/pdf":           true,
+	"text/plain; charset=utf-8": true,
+}
+
+var blobStore storageAPI.BlobStore
+
+// SetBlobStore sets the store used to save attachments
+func SetBlobStore(store storageAPI.BlobStore) {
+	blobStore = store
+}
+
+type upload struct {
+	filename string
+	mimeType string
+	content  []byte
+}
+
+// readUploads reads and validates the files sent in the attachments field of a multipart form
+func readUploads(r *http.Request) ([]upload, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+	var uploads []upload
+	for _, header := range r.MultipartForm.File[attachmentsField] {
+		// browsers send an empty part when no file is selected
+		if header.Filename == "" && header.Size == 0 {
+			continue
+		}
+		if len(uploads) == maxAttachments {
+			return nil, fmt.Errorf("too many attachments, the maximum is %d", maxAttachments)
+		}
+		if header.Size > maxAttachmentSize {
+			return nil, fmt.Errorf("%s is too large, the maximum is %d MB", header.Filename, maxAttachmentSize>>20)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(io.LimitReader(file, maxAttachmentSize+1))
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(content) == 0 {
+			return nil, fmt.Errorf("%s is empty", header.Filename)
+		}
+		if int64(len(content)) > maxAttachmentSize {
+			return nil, fmt.Errorf("%s is too large, the maximum is %d MB", header.Filename, maxAttachmentSize>>20)
+		}
+		// the type declared by the client is ignored, only the content decides
+		mimeType := http.DetectContentType(content)
+		if !allowedMimeTypes[mimeType] {
+			return nil, fmt.Errorf("%s has a file type that is not allowed", header.Filename)
+		}
+		if strings.HasPrefix(mimeType, "image/") {
+			config, _, err := image.DecodeConfig(bytes.NewReader(content))
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a valid image", header.Filename)
+			}
+			if config.Width*config.Height > maxImagePixels {
+				return nil, fmt.Errorf("%s has too many pixels", header.Filename)
+			}
+		}
+		uploads = append(uploads, upload{filename: cleanFilename(header.Filename), mimeType: mimeType, content: content})
+	}
+	return uploads, nil
+}
+
+// cleanFilename keeps the base name of an uploaded file without control characters
+func cleanFilename(filename string) string {
+	filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	filename = strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 {
+			return -1
+		}
+		return r
+	}, filename)
+	if filename == "" || filename == "." || filename == "/" {
+		return "attachment"
+	}
+	return filename
+}
+
+// storeUploads stores the uploads in the blob store and returns the attachments to add to the post
+func storeUploads(uploads []upload, username string) ([]databaseAPI.Attachment, error) {
+	var attachments []databaseAPI.Attachment
+	for _, upload := range uploads {
+		// blobs are addressed by their content, so the same file uploaded twice is stored once
+		sum := sha256.Sum256(upload.content)
+		attachment := databaseAPI.Attachment{
+			Username: username,
+			Filename: upload.filename,
+			MimeType: upload.mimeType,
+			Size:     int64(len(upload.content)),
+			BlobKey:  hex.EncodeToString(sum[:]),
+		}
+		attachments = append(attachments, attachment)
+		if err := blobStore.Put(attachment.BlobKey, bytes.NewReader(upload.content)); err != nil {
+			return attachments, err
+		}
+		if strings.HasPrefix(upload.mimeType, "image/") {
+			thumbnail, err := makeThumbnail(upload.content)
+			if err != nil {
+				return attachments, err
+			}
+			attachments[len(attachments)-1].ThumbnailKey = attachment.BlobKey + "-thumbnail"
+			if err := blobStore.Put(attachment.BlobKey+"-thumbnail", bytes.NewReader(thumbnail)); err != nil {
+				return attachments, err
+			}
+		}
+	}
+	return attachments, nil
+}
+
+// removeUploads removes the blobs of attachments that couldn't be added to a post, unless other attachments use them
+func removeUploads(r *http.Request, attachments []databaseAPI.Attachment) {
+	var blobKeys []string
+	for _, attachment := range attachments {
+		blobKeys = append(blobKeys, attachment.BlobKey)
+		if attachment.ThumbnailKey != "" {
+			blobKeys = append(blobKeys, attachment.ThumbnailKey)
+		}
+	}
+	unusedBlobs, err := databaseAPI.UnusedBlobs(database, blobKeys)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "finding unused blobs failed", "err", err)
+		return
+	}
+	for _, key := range unusedBlobs {
+		if err := blobStore.Delete(key); err != nil {
+			logger.ErrorContext(r.Context(), "blob deletion failed", "key", key, "err", err)
+		}
+	}
+}
+
+// makeThumbnail returns a PNG image fitting in a thumbnailSize square
+func makeThumbnail(content []byte) ([]byte, error) {
+	source, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbnailSize || height > thumbnailSize {
+		if width > height {
+			height = height * thumbnailSize / width
+			width = thumbnailSize
+		} else {
+			width = width * thumbnailSize / height
+			height = thumbnailSize
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(thumbnail, thumbnail.Bounds(), source, bounds, draw.Over, nil)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, thumbnail); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DisplayAttachment serves an attachment, or its thumbnail when thumbnail=1
+func DisplayAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	// attachments are visible to everyone who can see their post, GetAttachment doesn't return
+	// the attachments of deleted posts
+	attachment, err := databaseAPI.GetAttachment(database, r.URL.Query().Get("id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	key, mimeType := attachment.BlobKey, attachment.MimeType
+	if r.URL.Query().Get("thumbnail") == "1" && attachment.ThumbnailKey != "" {
+		key, mimeType = attachment.ThumbnailKey, "image/png"
+	}
+	file, err := blobStore.Open(key)
+	if errors.Is(err, storageAPI.ErrNotFound) {
+		writeStatus(w, r, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	// blobs never change, but the max age stays short so that access checks apply again once a post is deleted,
+	// the ETag makes revalidation cheap
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(attachmentMaxAge.Seconds())))
+	w.Header().Set("ETag", `"`+key+`"`)
+	if !strings.HasPrefix(mimeType, "image/") {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	}
+	http.ServeContent(w, r, "", time.Time{}, file)
+}
@@ -2,10 +2,10 @@ package webAPI
 
 import (
 	"FORUM-GO/databaseAPI"
-	"fmt"
+	"FORUM-GO/logAPI"
+	"FORUM-GO/metricsAPI"
+	"errors"
 	uuid "github.com/satori/go.uuid"
-	"golang.org/x/crypto/bcrypt"
-	"html/template"
 	"net/http"
 	"time"
 )
@@ -17,28 +17,43 @@ type Error struct {
 // RegisterApi handles the Register api
 func RegisterApi(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		fmt.Fprintf(w, "ParseForm() err: %v", err)
+		writeFormError(w, r, err)
 		return
 	}
 	username := r.FormValue("username")
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 	value := uuid.NewV4().String()
-	expiration := time.Now().Add(31 * 24 * time.Hour)
+	expiration := time.Now().Add(sessionLength)
 
-	if username == "" || email == "" || password == "" {
+	if username == "" || email == "" || password == "" || username == databaseAPI.DeletedUsername {
 		http.Redirect(w, r, "/register?err=invalid_informations", http.StatusFound)
 		return
 	}
-	if !databaseAPI.UsernameNotTaken(database, username) {
+	usernameFree, err := store.UsernameNotTaken(username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !usernameFree {
 		http.Redirect(w, r, "/register?err=username_taken", http.StatusFound)
 		return
 	}
-	if !databaseAPI.EmailNotTaken(database, email) {
+	emailFree, err := store.EmailNotTaken(email)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !emailFree {
 		http.Redirect(w, r, "/register?err=email_taken", http.StatusFound)
 		return
 	}
-	databaseAPI.AddUser(database, username, email, password, value, expiration.Format("2006-01-02 15:04:05"))
+	if err := store.AddUser(username, email, password, value, databaseAPI.FormatTime(expiration)); err != nil {
+		// another registration took the username or the email since they were checked
+		writeError(w, r, err)
+		return
+	}
+	logAPI.Audit(r.Context(), logger, logAPI.UserRegistered{Username: username, Email: email})
 	cookie := http.Cookie{Name: "SESSION", Value: value, Expires: expiration, Path: "/"}
 	http.SetCookie(w, &cookie)
 	http.Redirect(w, r, "/", http.StatusFound)
@@ -48,45 +63,66 @@ func RegisterApi(w http.ResponseWriter, r *http.Request) {
 //LoginApi handles the Login api
 func LoginApi(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		fmt.Fprintf(w, "ParseForm() err: %v", err)
+		writeFormError(w, r, err)
 		return
 	}
 	submittedEmail := r.FormValue("email")
 	submittedPassword := r.FormValue("password")
 
-	username, email, password := databaseAPI.GetUserInfo(database, submittedEmail)
-	now := time.Now().Format("2006-01-02 15:04:05")
-	if username == "" && email == "" && password == "" {
-		fmt.Println("Login failed (email not found) for " + submittedEmail + " at " + now)
+	username, email, password, err := store.GetUserInfo(submittedEmail)
+	if errors.Is(err, databaseAPI.ErrNotFound) {
+		metricsAPI.LoginFailures.WithLabelValues("email not found").Inc()
+		logAPI.Audit(r.Context(), logger, logAPI.LoginFailed{Email: submittedEmail, Reason: "email not found"})
 		http.Redirect(w, r, "/login?err=invalid_email", http.StatusFound)
 		return
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(password), []byte(submittedPassword)); err != nil {
-		fmt.Println("Login failed (wrong password) for " + submittedEmail + " at " + now)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := databaseAPI.CompareHashAndPassword(password, submittedPassword); err != nil {
+		metricsAPI.LoginFailures.WithLabelValues("wrong password").Inc()
+		logAPI.Audit(r.Context(), logger, logAPI.LoginFailed{Email: submittedEmail, Reason: "wrong password"})
 		http.Redirect(w, r, "/login?err=invalid_password", http.StatusFound)
 		return
 	}
-	expiration := time.Now().Add(31 * 24 * time.Hour)
+	banned, err := databaseAPI.IsBanned(database, username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if banned {
+		metricsAPI.LoginFailures.WithLabelValues("banned").Inc()
+		logAPI.Audit(r.Context(), logger, logAPI.LoginFailed{Email: submittedEmail, Reason: "banned"})
+		http.Redirect(w, r, "/login?err=banned", http.StatusFound)
+		return
+	}
+	expiration := time.Now().Add(sessionLength)
 	value := uuid.NewV4().String()
+	// update cookie in DB
+	if err := store.UpdateCookie(value, expiration, email); err != nil {
+		writeError(w, r, err)
+		return
+	}
 	cookie := http.Cookie{Name: "SESSION", Value: value, Expires: expiration, Path: "/"}
 	http.SetCookie(w, &cookie)
-	// update cookie in DB
-	databaseAPI.UpdateCookie(database, value, expiration, email)
-	fmt.Println("Logged in user: " + username + " with email: " + email + " at " + now)
+	logAPI.Audit(r.Context(), logger, logAPI.LoggedIn{Username: username, Email: email})
 	http.Redirect(w, r, "/", http.StatusFound)
 	return
 }
 
 // LogoutAPI deletes the session cookie from the database
 func LogoutAPI(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("SESSION")
-	username := databaseAPI.GetUser(database, cookie.Value)
-	now := time.Now().Format("2006-01-02 15:04:05")
-	if cookie != nil {
-		username := databaseAPI.GetUser(database, cookie.Value)
-		databaseAPI.Logout(database, username)
-	}
-	fmt.Println("User " + username + " logged out at " + now)
+	if username, err := sessionUser(r); err == nil {
+		if err := store.Logout(username); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAPI.Audit(r.Context(), logger, logAPI.LoggedOut{Username: username})
+	} else if !errors.Is(err, databaseAPI.ErrNotFound) {
+		writeError(w, r, err)
+		return
+	}
 	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 	return
 }
@@ -97,22 +133,68 @@ func isLoggedIn(r *http.Request) bool {
 	if err != nil {
 		return false
 	}
-	cookieExists := databaseAPI.CheckCookie(database, cookie.Value)
-	if !cookieExists {
+	expires, err := store.GetExpires(cookie.Value)
+	if err != nil {
+		if !errors.Is(err, databaseAPI.ErrNotFound) {
+			logger.ErrorContext(r.Context(), "session check failed", "err", err)
+		}
 		return false
 	}
-	expires := databaseAPI.GetExpires(database, cookie.Value)
+	return !isExpired(expires)
+}
+
+// sessionUser returns the username of the session of a request, ErrNotFound if it has none
+func sessionUser(r *http.Request) (string, error) {
+	cookie, err := r.Cookie("SESSION")
+	if err != nil {
+		return "", databaseAPI.ErrNotFound
+	}
+	return store.GetUser(cookie.Value)
+}
+
+// getCurrentUser returns the user of the request, with the number of unread notifications and their settings when
+// logged in
+func getCurrentUser(r *http.Request) User {
+	anonymous := User{IsLoggedIn: false, Settings: databaseAPI.DefaultUserSettings(), location: time.Local}
+	if !isLoggedIn(r) {
+		return anonymous
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		return anonymous
+	}
+	unread, _ := databaseAPI.CountUnreadNotifications(database, username)
+	admin, _ := store.IsAdmin(username)
+	settings, err := databaseAPI.GetUserSettings(database, username)
+	if err != nil {
+		settings = databaseAPI.DefaultUserSettings()
+	}
+	return User{IsLoggedIn: true, Username: username, UnreadNotifications: unread, IsAdmin: admin, Settings: settings, location: userLocation(settings)}
+}
+
+// SessionUsername returns the username of the session of a request, or "" if it has none, for the access log
+func SessionUsername(r *http.Request) string {
+	username, _ := sessionUser(r)
+	return username
+}
 
-	if isExpired(expires) {
+// isAdmin returns true if the logged-in user is an admin
+func isAdmin(r *http.Request) bool {
+	if !isLoggedIn(r) {
 		return false
 	}
-	return true
+	username, err := sessionUser(r)
+	if err != nil {
+		return false
+	}
+	admin, _ := store.IsAdmin(username)
+	return admin
 }
 
 // isExpired returns true if the cookie has expired
 func isExpired(expires string) bool {
-	expiresTime, _ := time.Parse("2006-01-02 15:04:05", expires)
-	return time.Now().After(expiresTime)
+	expiresTime, err := databaseAPI.ParseTime(expires)
+	return err != nil || time.Now().After(expiresTime)
 }
 
 // Register displays the Register page
@@ -128,8 +210,7 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	if error == "username_taken" {
 		payload = Error{Message: "Username already taken"}
 	}
-	t, _ := template.ParseGlob("public/HTML/*.html")
-	t.ExecuteTemplate(w, "registerForm.html", payload)
+	renderTemplate(w, r, "registerForm.html", payload)
 }
 
 // Login displays template for the Login page
@@ -142,6 +223,8 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	if error == "invalid_password" {
 		payload = Error{Message: "Invalid password"}
 	}
-	t, _ := template.ParseGlob("public/HTML/*.html")
-	t.ExecuteTemplate(w, "signinForm.html", payload)
+	if error == "banned" {
+		payload = Error{Message: "This account is banned"}
+	}
+	renderTemplate(w, r, "signinForm.html", payload)
 }
@@ -0,0 +1,139 @@
+package webAPI
+
+import (
+	"FORUM-GO/backupAPI"
+	"FORUM-GO/databaseAPI"
+	"FORUM-GO/logAPI"
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupDir is the directory of the backups made from the admin page and by the scheduler, of which the latest
+// backupKeep are kept
+var (
+	backupDir  = "backups"
+	backupKeep = 7
+)
+
+type BackupsPage struct {
+	User    User
+	Dir     string
+	Keep    int
+	Backups []backupAPI.Backup
+	Created string
+}
+
+// SetBackups sets the directory of the backups and the number of backups kept in it, 0 keeping all of them
+func SetBackups(dir string, keep int) {
+	backupDir = dir
+	backupKeep = keep
+}
+
+// RunBackupScheduler backs the database up at every interval until the context is canceled
+func RunBackupScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			createBackup("the scheduler")
+		}
+	}
+}
+
+// createBackup backs the database up in the backups directory and logs it
+func createBackup(by string) (string, error) {
+	name, err := backupAPI.Create(database, backupDir, backupKeep)
+	if err != nil {
+		logger.Error("backup failed", "by", by, "err", err)
+		return name, err
+	}
+	logAPI.Audit(context.Background(), logger, logAPI.BackupCreated{Path: filepath.Join(backupDir, name), By: by})
+	return name, nil
+}
+
+// DisplayBackups displays the backups of the database to the admins
+func DisplayBackups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if !isAdmin(r) {
+		writeStatus(w, r, http.StatusForbidden)
+		return
+	}
+	payload := BackupsPage{User: getCurrentUser(r), Dir: backupDir, Keep: backupKeep, Created: r.URL.Query().Get("created")}
+	var err error
+	if payload.Backups, err = backupAPI.List(backupDir); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	renderTemplate(w, r, "backups.html", payload)
+}
+
+// BackupsApi backs the database up in the backups directory with POST, and downloads a backup given by name with GET
+func BackupsApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if !isAdmin(r) {
+		writeStatus(w, r, http.StatusForbidden)
+		return
+	}
+	if r.Method == "GET" {
+		name := r.URL.Query().Get("name")
+		// only the backups can be downloaded, not any file of the directory
+		if !backupAPI.IsBackupName(name) {
+			writeStatus(w, r, http.StatusBadRequest)
+			return
+		}
+		// the sessions are removed from the downloaded copy, which leaves the server
+		dir, err := os.MkdirTemp("", "forum-download-*")
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		defer os.RemoveAll(dir)
+		download := filepath.Join(dir, name)
+		if err := backupAPI.WriteWithoutSessions(filepath.Join(backupDir, name), download); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				writeStatus(w, r, http.StatusNotFound)
+				return
+			}
+			writeError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.sqlite3")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		// a large database takes longer to download than the write timeout of the server
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		http.ServeFile(w, r, download)
+		return
+	}
+	name, err := createBackup(getCurrentUser(r).Username)
+	if errors.Is(err, databaseAPI.ErrPostgresBackup) {
+		writeStatus(w, r, http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/backups?created="+name, http.StatusFound)
+}
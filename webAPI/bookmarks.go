@@ -0,0 +1,77 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxFolderLength = 50
+
+// BookmarksApi returns the bookmarks of the user as JSON on GET, optionally only the ones of a folder. On POST, it saves
+// the post in the folder with bookmark=1 and removes it from the bookmarks otherwise.
+func BookmarksApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		if r.Method == "GET" {
+			writeStatus(w, r, http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if r.Method == "GET" {
+		bookmarks, err := databaseAPI.GetBookmarks(database, username, r.URL.Query().Get("folder"))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if bookmarks == nil {
+			bookmarks = []databaseAPI.Bookmark{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bookmarks)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	postId, err := strconv.Atoi(r.FormValue("postId"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid post")
+		return
+	}
+	if _, err := store.GetPost(strconv.Itoa(postId)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	folder := strings.TrimSpace(r.FormValue("folder"))
+	if utf8.RuneCountInString(folder) > maxFolderLength {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid folder, the maximum length is %d", maxFolderLength))
+		return
+	}
+	if r.FormValue("bookmark") == "1" {
+		err = databaseAPI.SaveBookmark(database, username, postId, folder, time.Now())
+	} else {
+		err = databaseAPI.DeleteBookmark(database, username, postId)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, localRedirect(r.FormValue("redirect")), http.StatusFound)
+}
@@ -0,0 +1,335 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"FORUM-GO/logAPI"
+	"FORUM-GO/mailAPI"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	digestCheckInterval = time.Hour
+	replyEmailQueueSize = 100
+	listDigest          = "digest"
+	listReplies         = "replies"
+	listAll             = "all"
+)
+
+type DigestEmail struct {
+	Username       string
+	ForumURL       string
+	PreferencesURL string
+	UnsubscribeURL string
+	Categories     []string
+	Posts          []databaseAPI.Post
+}
+
+type ReplyEmail struct {
+	Username       string
+	ForumURL       string
+	PreferencesURL string
+	UnsubscribeURL string
+	Message        string
+	PostURL        string
+}
+
+type EmailsPage struct {
+	User        User
+	Preferences databaseAPI.EmailPreferences
+	Categories  []string
+	Enabled     bool
+}
+
+type UnsubscribePage struct {
+	Token string
+	List  string
+	Valid bool
+	Done  bool
+}
+
+var mailer mailAPI.Mailer
+
+// replyEmails queues the notifications to email, sent one at a time by RunReplyEmailSender
+var replyEmails = make(chan databaseAPI.Notification, replyEmailQueueSize)
+var mailFrom = "Forum <forum@localhost>"
+var forumURL = "http://localhost:8000"
+
+// digestInterval is the interval between two digests of a user, which show at most digestPostsLimit posts
+var (
+	digestInterval   = 7 * 24 * time.Hour
+	digestPostsLimit = 10
+)
+
+// SetMailer sets the mailer and the sender address of the emails, no email is sent while the mailer is nil
+func SetMailer(m mailAPI.Mailer, from string) {
+	mailer = m
+	if from != "" {
+		mailFrom = from
+	}
+}
+
+// SetForumURL sets the public URL of the forum, used for the links in emails
+func SetForumURL(url string) {
+	forumURL = url
+}
+
+// SetDigest sets the interval between two digests of a user and the maximum number of posts in a digest
+func SetDigest(interval time.Duration, postsLimit int) {
+	digestInterval = interval
+	digestPostsLimit = postsLimit
+}
+
+// renderEmail renders the text and the HTML templates of an email
+func renderEmail(name string, data interface{}) (string, string, error) {
+	set := templates.Load()
+	if set == nil {
+		return "", "", errors.New("templates not loaded")
+	}
+	var text, html bytes.Buffer
+	if err := set.textEmails.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return "", "", err
+	}
+	if err := set.htmlEmails.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// unsubscribeURL returns the link unsubscribing the owner of a token from a list of emails
+func unsubscribeURL(token string, list string) string {
+	return forumURL + "/unsubscribe?" + url.Values{"token": {token}, "list": {list}}.Encode()
+}
+
+// sendEmail sends an email to a user, with the headers allowing mail clients to unsubscribe in one click
+func sendEmail(username string, subject string, name string, data interface{}, unsubscribe string) error {
+	email, err := store.GetUserEmail(username)
+	if err != nil {
+		return err
+	}
+	text, html, err := renderEmail(name, data)
+	if err != nil {
+		return err
+	}
+	return mailer.Send(mailAPI.Message{
+		From:    mailFrom,
+		To:      email,
+		Subject: subject,
+		Text:    text,
+		Html:    html,
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribe + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+}
+
+// queueReplyEmail queues the email of a notification about a reply, which is dropped if the queue is full
+func queueReplyEmail(notification databaseAPI.Notification) {
+	if mailer == nil {
+		return
+	}
+	select {
+	case replyEmails <- notification:
+	default:
+		logger.Warn("reply email dropped, the queue is full", "username", notification.Username)
+	}
+}
+
+// RunReplyEmailSender sends the queued reply emails until the context is canceled, then sends the ones left in the
+// queue before returning
+func RunReplyEmailSender(ctx context.Context) {
+	for {
+		select {
+		case notification := <-replyEmails:
+			sendReplyEmail(notification)
+		case <-ctx.Done():
+			for {
+				select {
+				case notification := <-replyEmails:
+					sendReplyEmail(notification)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// sendReplyEmail emails a notification about a reply to its user, if they want reply emails
+func sendReplyEmail(notification databaseAPI.Notification) {
+	if mailer == nil {
+		return
+	}
+	preferences, err := databaseAPI.GetEmailPreferences(database, notification.Username)
+	if err != nil || !preferences.Replies {
+		return
+	}
+	unsubscribe := unsubscribeURL(preferences.UnsubscribeToken, listReplies)
+	err = sendEmail(notification.Username, notification.Message, "reply", ReplyEmail{
+		Username:       notification.Username,
+		ForumURL:       forumURL,
+		PreferencesURL: forumURL + "/emails",
+		UnsubscribeURL: unsubscribe,
+		Message:        notification.Message,
+		PostURL:        postURL(notification.PostId, notification.CommentId),
+	}, unsubscribe)
+	if err != nil {
+		logger.Error("reply email failed", "username", notification.Username, "err", err)
+	}
+}
+
+// RunDigestScheduler sends the weekly digests until the context is canceled
+func RunDigestScheduler(ctx context.Context) {
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+	for {
+		sendDueDigests(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDueDigests sends a digest to the users following categories who didn't get one for a week
+func sendDueDigests(now time.Time) {
+	if mailer == nil {
+		return
+	}
+	usernames, err := databaseAPI.GetSubscribers(database, subscriptionCategory)
+	if err != nil {
+		logger.Error("digests failed", "err", err)
+		return
+	}
+	for _, username := range usernames {
+		preferences, err := databaseAPI.GetEmailPreferences(database, username)
+		if err != nil || !preferences.Digest {
+			continue
+		}
+		if preferences.LastDigestAt != "" {
+			lastDigest, err := databaseAPI.ParseTime(preferences.LastDigestAt)
+			if err == nil && now.Sub(lastDigest) < digestInterval {
+				continue
+			}
+		}
+		categories, err := databaseAPI.GetFollowed(database, username, subscriptionCategory)
+		if err != nil {
+			continue
+		}
+		posts, err := store.GetTopPostsInCategories(categories, now.Add(-digestInterval), digestPostsLimit)
+		if err != nil {
+			continue
+		}
+		// a week without posts sends nothing, but still counts as sent so the next digest covers the next week
+		if len(posts) > 0 {
+			unsubscribe := unsubscribeURL(preferences.UnsubscribeToken, listDigest)
+			err = sendEmail(username, "Your weekly digest", "digest", DigestEmail{
+				Username:       username,
+				ForumURL:       forumURL,
+				PreferencesURL: forumURL + "/emails",
+				UnsubscribeURL: unsubscribe,
+				Categories:     categories,
+				Posts:          posts,
+			}, unsubscribe)
+			if err != nil {
+				logger.Error("digest failed", "username", username, "err", err)
+				continue
+			}
+			logAPI.Audit(context.Background(), logger, logAPI.DigestSent{Username: username})
+		}
+		databaseAPI.SetLastDigest(database, username, now)
+	}
+}
+
+// DisplayEmails displays the email preferences and the followed categories of the user
+func DisplayEmails(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	payload := EmailsPage{User: getCurrentUser(r), Enabled: mailer != nil}
+	preferences, err := databaseAPI.GetEmailPreferences(database, payload.User.Username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	categories, err := databaseAPI.GetFollowed(database, payload.User.Username, subscriptionCategory)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	payload.Preferences = preferences
+	payload.Categories = categories
+	renderTemplate(w, r, "emails.html", payload)
+}
+
+// EmailsApi saves the email preferences of the user
+func EmailsApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := databaseAPI.SetEmailPreferences(database, username, r.FormValue("digest") == "1", r.FormValue("replies") == "1"); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/emails", http.StatusFound)
+}
+
+// Unsubscribe asks to confirm unsubscribing from a list of emails, and unsubscribes on POST. Mail clients
+// supporting one-click unsubscribe send the POST themselves.
+func Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	payload := UnsubscribePage{Token: r.URL.Query().Get("token"), List: r.URL.Query().Get("list")}
+	username, err := databaseAPI.GetUsernameByUnsubscribeToken(database, payload.Token)
+	if err != nil && !errors.Is(err, databaseAPI.ErrNotFound) {
+		writeError(w, r, err)
+		return
+	}
+	payload.Valid = err == nil && (payload.List == listDigest || payload.List == listReplies || payload.List == listAll)
+	if payload.Valid && r.Method == "POST" {
+		preferences, err := databaseAPI.GetEmailPreferences(database, username)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		digest := preferences.Digest && payload.List == listReplies
+		replies := preferences.Replies && payload.List == listDigest
+		if err := databaseAPI.SetEmailPreferences(database, username, digest, replies); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		payload.Done = true
+	}
+	if !payload.Valid {
+		renderTemplateStatus(w, r, http.StatusNotFound, "unsubscribe.html", payload)
+		return
+	}
+	renderTemplate(w, r, "unsubscribe.html", payload)
+}
@@ -0,0 +1,175 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+type ErrorPage struct {
+	User    User
+	Status  int
+	Title   string
+	Message string
+}
+
+// errorMessages are the messages of the error pages, by status
+var errorMessages = map[int]string{
+	http.StatusBadRequest:            "The request is invalid.",
+	http.StatusUnauthorized:          "You have to log in to do this.",
+	http.StatusForbidden:             "You are not allowed to do this.",
+	http.StatusNotFound:              "The page you are looking for doesn't exist.",
+	http.StatusMethodNotAllowed:      "This page doesn't accept this method.",
+	http.StatusConflict:              "This conflicts with the data of the forum, it may already exist.",
+	http.StatusRequestEntityTooLarge: "The request is too large.",
+	http.StatusInternalServerError:   "Something went wrong on our side, try again later.",
+	http.StatusServiceUnavailable:    "The forum is unavailable for now, try again later.",
+}
+
+// errorStatus returns the HTTP status of an error: 404 when the row of the database doesn't exist, 409 when a change
+// conflicts with the data and 500 otherwise
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, databaseAPI.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, databaseAPI.ErrConflict), errors.Is(err, databaseAPI.ErrConstraint):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError renders the error page of the status of an error, logging the unexpected errors
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		logger.ErrorContext(r.Context(), "request failed", "path", r.URL.Path, "err", err)
+	}
+	renderError(w, r, status, "")
+}
+
+// writeFormError renders the error page of a form that couldn't be parsed: 413 when it is too large and 400 otherwise
+func writeFormError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeStatus(w, r, http.StatusRequestEntityTooLarge)
+		return
+	}
+	renderError(w, r, http.StatusBadRequest, "The form is invalid.")
+}
+
+// writeStatus renders the error page of a status with its default message
+func writeStatus(w http.ResponseWriter, r *http.Request, status int) {
+	renderError(w, r, status, "")
+}
+
+// renderError renders the error page of a status, or a JSON object for the clients accepting JSON rather than HTML.
+// An empty message is replaced by the default message of the status.
+func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if message == "" {
+		message = errorMessages[status]
+	}
+	payload := ErrorPage{Status: status, Title: http.StatusText(status), Message: message}
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(struct {
+			Status  int    `json:"status"`
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}{payload.Status, payload.Title, payload.Message})
+		return
+	}
+	payload.User = getCurrentUser(r)
+	var page bytes.Buffer
+	if err := executeTemplate(&page, "error.html", payload); err != nil {
+		logger.ErrorContext(r.Context(), "unable to render the error page", "err", err)
+		http.Error(w, message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	page.WriteTo(w)
+}
+
+// wantsJSON returns true if the client accepts JSON and doesn't prefer HTML, as the API clients do, while browsers
+// accept HTML first
+func wantsJSON(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch {
+		case mediaType == "text/html":
+			return false
+		case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+			return true
+		}
+	}
+	return false
+}
+
+// Recover recovers the panics of the handlers, logging them with their stack trace and rendering the error page of
+// a 500 if the response hasn't started yet
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &startRecorder{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// the server aborts the response without logging it
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			logger.ErrorContext(r.Context(), "handler panicked", "method", r.Method, "path", r.URL.Path,
+				"panic", recovered, "stack", string(debug.Stack()))
+			if recorder.started {
+				// the status is sent already, the client gets a truncated response
+				panic(http.ErrAbortHandler)
+			}
+			renderError(w, r, http.StatusInternalServerError, "")
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// startRecorder records whether a response has started
+type startRecorder struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *startRecorder) WriteHeader(status int) {
+	// the informational responses, as 103 Early Hints, come before the real status
+	if status >= 200 {
+		w.started = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *startRecorder) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the response, for the event streams
+func (w *startRecorder) Flush() {
+	w.started = true
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the recorded ResponseWriter, for http.ResponseController
+func (w *startRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
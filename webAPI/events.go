@@ -0,0 +1,273 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	maxEventSubscriptions = 1000
+	eventBufferSize       = 16
+	eventKeepAlive        = 30 * time.Second
+	eventRetry            = 5 * time.Second
+)
+
+// Event is a message published to the clients following a topic
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+type CommentEvent struct {
+	Id        int       `json:"id"`
+	PostId    int       `json:"postId"`
+	ParentId  int       `json:"parentId"`
+	Username  string    `json:"username"`
+	Html      string    `json:"html"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ScoreEvent struct {
+	PostId    int `json:"postId"`
+	UpVotes   int `json:"upVotes"`
+	DownVotes int `json:"downVotes"`
+}
+
+type NotificationEvent struct {
+	Id        int    `json:"id"`
+	PostId    int    `json:"postId"`
+	CommentId int    `json:"commentId"`
+	Message   string `json:"message"`
+	Unread    int    `json:"unread"`
+}
+
+// ErrTooManySubscriptions is returned when the hub already serves its maximum number of clients
+var ErrTooManySubscriptions = errors.New("too many event subscriptions")
+
+// ErrHubClosed is returned when the hub has been closed by the shutdown of the server
+var ErrHubClosed = errors.New("event hub closed")
+
+// Subscription receives the events published to a topic
+type Subscription struct {
+	topic  string
+	events chan Event
+}
+
+// Hub is an in-process publish/subscribe hub. Publishing never blocks: a subscriber whose buffer is full
+// is disconnected and its client reconnects, so one slow client can't hold back the others.
+type Hub struct {
+	lock             sync.Mutex
+	topics           map[string]map[*Subscription]bool
+	count            int
+	maxSubscriptions int
+	closed           bool
+}
+
+// NewHub returns a hub accepting up to maxSubscriptions subscribers at the same time
+func NewHub(maxSubscriptions int) *Hub {
+	return &Hub{topics: map[string]map[*Subscription]bool{}, maxSubscriptions: maxSubscriptions}
+}
+
+// Subscribe returns a Subscription receiving the events published to a topic
+func (hub *Hub) Subscribe(topic string) (*Subscription, error) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	if hub.closed {
+		return nil, ErrHubClosed
+	}
+	if hub.count >= hub.maxSubscriptions {
+		return nil, ErrTooManySubscriptions
+	}
+	s := &Subscription{topic: topic, events: make(chan Event, eventBufferSize)}
+	if hub.topics[topic] == nil {
+		hub.topics[topic] = map[*Subscription]bool{}
+	}
+	hub.topics[topic][s] = true
+	hub.count++
+	return s, nil
+}
+
+// Unsubscribe stops a Subscription, it does nothing if the Subscription has already been dropped
+func (hub *Hub) Unsubscribe(s *Subscription) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	hub.remove(s)
+}
+
+// remove deletes a Subscription and closes its channel, the lock must be held
+func (hub *Hub) remove(s *Subscription) {
+	subscriptions := hub.topics[s.topic]
+	if !subscriptions[s] {
+		return
+	}
+	delete(subscriptions, s)
+	if len(subscriptions) == 0 {
+		delete(hub.topics, s.topic)
+	}
+	hub.count--
+	close(s.events)
+}
+
+// Publish sends an event to all the subscribers of a topic
+func (hub *Hub) Publish(topic string, event Event) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	for s := range hub.topics[topic] {
+		select {
+		case s.events <- event:
+		default:
+			hub.remove(s)
+		}
+	}
+}
+
+// Close drops all the subscriptions, ending their streams, and refuses the new ones
+func (hub *Hub) Close() {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+	hub.closed = true
+	for _, subscriptions := range hub.topics {
+		for s := range subscriptions {
+			hub.remove(s)
+		}
+	}
+}
+
+var hub = NewHub(maxEventSubscriptions)
+
+// CloseEvents ends the event streams so that the server can shut down, their clients reconnect once it is back
+func CloseEvents() {
+	hub.Close()
+}
+
+// postTopic is the topic of the events about a post
+func postTopic(postId int) string {
+	return "post:" + strconv.Itoa(postId)
+}
+
+// userTopic is the topic of the events for a user
+func userTopic(username string) string {
+	return "user:" + username
+}
+
+// publishComment publishes a new comment to the clients displaying its post
+func publishComment(comment databaseAPI.Comment) {
+	hub.Publish(postTopic(comment.PostId), Event{Type: "comment", Data: CommentEvent{
+		Id:        comment.Id,
+		PostId:    comment.PostId,
+		ParentId:  comment.ParentId,
+		Username:  comment.Username,
+		Html:      string(renderMarkdown(comment.Content)),
+		CreatedAt: comment.CreatedAt,
+	}})
+}
+
+// publishScore publishes the current votes of a post to the clients displaying it
+func publishScore(postId int) {
+	post, err := store.GetPost(strconv.Itoa(postId))
+	if err != nil {
+		logger.Error("publishing the score failed", "post_id", postId, "err", err)
+		return
+	}
+	hub.Publish(postTopic(postId), Event{Type: "score", Data: ScoreEvent{PostId: postId, UpVotes: post.UpVotes, DownVotes: post.DownVotes}})
+}
+
+// publishNotification publishes a new notification to the clients of its user
+func publishNotification(notification databaseAPI.Notification) {
+	unread, _ := databaseAPI.CountUnreadNotifications(database, notification.Username)
+	hub.Publish(userTopic(notification.Username), Event{Type: "notification", Data: NotificationEvent{
+		Id:        notification.Id,
+		PostId:    notification.PostId,
+		CommentId: notification.CommentId,
+		Message:   notification.Message,
+		Unread:    unread,
+	}})
+}
+
+// PostEventsApi streams the new comments and the score changes of a post
+func PostEventsApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	postId, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		writeStatus(w, r, http.StatusBadRequest)
+		return
+	}
+	// a post that doesn't exist has no events, its stream isn't opened
+	if _, err := store.GetPost(strconv.Itoa(postId)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	streamEvents(w, r, postTopic(postId))
+}
+
+// UserEventsApi streams the notifications of the logged-in user
+func UserEventsApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		writeStatus(w, r, http.StatusUnauthorized)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	streamEvents(w, r, userTopic(username))
+}
+
+// streamEvents sends the events of a topic as Server-Sent Events until the client disconnects
+func streamEvents(w http.ResponseWriter, r *http.Request, topic string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeStatus(w, r, http.StatusInternalServerError)
+		return
+	}
+	s, err := hub.Subscribe(topic)
+	if err == ErrTooManySubscriptions || err == ErrHubClosed {
+		// the client retries later, the pages keep working without live updates meanwhile
+		w.Header().Set("Retry-After", strconv.Itoa(int(eventRetry.Seconds())))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	defer hub.Unsubscribe(s)
+	// the stream lasts longer than the write timeout of the server
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry.Milliseconds())
+	flusher.Flush()
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-s.events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// feedTitle is the title of the feeds, which show the latest feedEntries posts or comments
+var (
+	feedTitle   = "HAPPY FEET"
+	feedEntries = 20
+)
+
+// SetFeeds sets the title of the feeds and their number of entries
+func SetFeeds(title string, entries int) {
+	feedTitle = title
+	feedEntries = entries
+}
+
+// syndicationFeed is a feed independent of its format, rendered as RSS or Atom
+type syndicationFeed struct {
+	Title   string
+	Link    string
+	Self    string
+	Updated time.Time
+	Entries []syndicationEntry
+}
+
+type syndicationEntry struct {
+	Id        string
+	Title     string
+	Link      string
+	Author    string
+	Content   string
+	Published time.Time
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Dc      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Guid        rssGuid `xml:"guid"`
+	Creator     string  `xml:"dc:creator"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	Id        string      `xml:"id"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Author    atomAuthor  `xml:"author"`
+	Link      atomLink    `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// DisplayRssFeed serves the RSS 2.0 feed of the front page, or of the category, the user or the thread given in the query
+func DisplayRssFeed(w http.ResponseWriter, r *http.Request) {
+	serveFeed(w, r, "application/rss+xml; charset=utf-8", "/feeds/rss", renderRss)
+}
+
+// DisplayAtomFeed serves the Atom feed of the front page, or of the category, the user or the thread given in the query
+func DisplayAtomFeed(w http.ResponseWriter, r *http.Request) {
+	serveFeed(w, r, "application/atom+xml; charset=utf-8", "/feeds/atom", renderAtom)
+}
+
+// serveFeed builds a feed and serves it in a format, answering conditional requests with 304 Not Modified
+func serveFeed(w http.ResponseWriter, r *http.Request, contentType string, path string, render func(syndicationFeed) ([]byte, error)) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	feed, err := buildFeed(r.URL.Query(), path)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	body, err := render(feed)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	// readers always revalidate, which is cheap with the ETag and Last-Modified
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", feed.Updated, bytes.NewReader(body))
+}
+
+// buildFeed returns the latest posts of the front page, a category or a user, or the latest comments of a thread.
+// It returns ErrNotFound if the category, the user or the thread doesn't exist.
+func buildFeed(query url.Values, path string) (syndicationFeed, error) {
+	feed := syndicationFeed{Title: feedTitle, Link: forumURL + "/", Self: forumURL + path}
+	var posts []databaseAPI.Post
+	var err error
+	switch {
+	case query.Get("thread") != "":
+		post, err := store.GetPost(query.Get("thread"))
+		if err != nil {
+			return feed, err
+		}
+		feed.Title += " - Comments on \"" + post.Title + "\""
+		feed.Link = postURL(post.Id, 0)
+		feed.Self += "?" + url.Values{"thread": {strconv.Itoa(post.Id)}}.Encode()
+		feed.Updated = post.CreatedAt
+		comments, err := store.GetComments(strconv.Itoa(post.Id))
+		if err != nil {
+			return feed, err
+		}
+		for i := len(comments) - 1; i >= 0 && len(feed.Entries) < feedEntries; i-- {
+			comment := comments[i]
+			feed.Entries = append(feed.Entries, syndicationEntry{
+				Id:        postURL(post.Id, comment.Id),
+				Title:     "Comment by " + comment.Username + " on \"" + post.Title + "\"",
+				Link:      postURL(post.Id, comment.Id),
+				Author:    comment.Username,
+				Content:   string(renderMarkdown(comment.Content)),
+				Published: comment.CreatedAt,
+			})
+		}
+		setFeedUpdated(&feed)
+		return feed, nil
+	case query.Get("category") != "":
+		category := query.Get("category")
+		var categories []string
+		if categories, err = store.GetCategories(); err != nil {
+			return feed, err
+		}
+		if !inArray(category, categories) {
+			return feed, databaseAPI.ErrNotFound
+		}
+		feed.Title += " - " + category
+		feed.Link = forumURL + "/filter?" + url.Values{"by": {"category"}, "category": {category}}.Encode()
+		feed.Self += "?" + url.Values{"category": {category}}.Encode()
+		posts, err = store.GetLatestPosts(category, "", feedEntries)
+	case query.Get("user") != "":
+		username := query.Get("user")
+		var free bool
+		if free, err = store.UsernameNotTaken(username); err != nil {
+			return feed, err
+		}
+		if free {
+			return feed, databaseAPI.ErrNotFound
+		}
+		feed.Title += " - Posts by " + username
+		feed.Self += "?" + url.Values{"user": {username}}.Encode()
+		posts, err = store.GetLatestPosts("", username, feedEntries)
+	default:
+		posts, err = store.GetLatestPosts("", "", feedEntries)
+	}
+	if err != nil {
+		return feed, err
+	}
+	for _, post := range posts {
+		feed.Entries = append(feed.Entries, syndicationEntry{
+			Id:        postURL(post.Id, 0),
+			Title:     post.Title,
+			Link:      postURL(post.Id, 0),
+			Author:    post.Username,
+			Content:   string(renderMarkdown(post.Content)),
+			Published: post.CreatedAt,
+		})
+	}
+	setFeedUpdated(&feed)
+	return feed, nil
+}
+
+// setFeedUpdated sets the update time of a feed to the time of its latest entry, if it is later
+func setFeedUpdated(feed *syndicationFeed) {
+	for _, entry := range feed.Entries {
+		if entry.Published.After(feed.Updated) {
+			feed.Updated = entry.Published
+		}
+	}
+}
+
+// postURL returns the permanent link of a post, or of one of its comments if commentId isn't 0
+func postURL(postId int, commentId int) string {
+	link := forumURL + "/post?id=" + strconv.Itoa(postId)
+	if commentId != 0 {
+		link += "#comment-" + strconv.Itoa(commentId)
+	}
+	return link
+}
+
+// renderRss renders a feed as RSS 2.0
+func renderRss(feed syndicationFeed) ([]byte, error) {
+	document := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Dc:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       feed.Title,
+			Link:        feed.Link,
+			Description: feed.Title,
+			Self:        rssLink{Href: feed.Self, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if !feed.Updated.IsZero() {
+		document.Channel.LastBuildDate = feed.Updated.Format(time.RFC1123Z)
+	}
+	for _, entry := range feed.Entries {
+		document.Channel.Items = append(document.Channel.Items, rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			Guid:        rssGuid{IsPermaLink: true, Value: entry.Id},
+			Creator:     entry.Author,
+			PubDate:     entry.Published.Format(time.RFC1123Z),
+			Description: entry.Content,
+		})
+	}
+	return marshalFeed(document)
+}
+
+// renderAtom renders a feed as Atom
+func renderAtom(feed syndicationFeed) ([]byte, error) {
+	document := atomFeed{
+		Title:   feed.Title,
+		Id:      feed.Self,
+		Updated: feed.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feed.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: feed.Link, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, entry := range feed.Entries {
+		published := entry.Published.UTC().Format(time.RFC3339)
+		document.Entries = append(document.Entries, atomEntry{
+			Title:     entry.Title,
+			Id:        entry.Id,
+			Updated:   published,
+			Published: published,
+			Author:    atomAuthor{Name: entry.Author},
+			Link:      atomLink{Href: entry.Link, Rel: "alternate", Type: "text/html"},
+			Content:   atomContent{Type: "html", Body: entry.Content},
+		})
+	}
+	return marshalFeed(document)
+}
+
+// marshalFeed encodes a feed document with the XML declaration
+func marshalFeed(document interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,98 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// readinessTimeout is the time given to the database to answer the readiness check
+const readinessTimeout = 2 * time.Second
+
+// started is the time the process started, for the uptime of the health check
+var started = time.Now()
+
+// shuttingDown is set once the server starts shutting down, to fail the readiness check
+var shuttingDown atomic.Bool
+
+type HealthResponse struct {
+	Status string `json:"status"`
+	Uptime string `json:"uptime"`
+}
+
+type ReadinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// SetShuttingDown makes the readiness check fail, so that no new traffic is sent to the server while it shuts down
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// HealthzApi answers that the process is alive
+func HealthzApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	writeHealth(w, http.StatusOK, HealthResponse{Status: "ok", Uptime: time.Since(started).Round(time.Second).String()})
+}
+
+// ReadyzApi answers whether the server can serve requests: the database answers, its schema is the forum's, the
+// templates are loaded, and the server isn't shutting down
+func ReadyzApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	checks := map[string]error{
+		"database":  database.PingContext(ctx),
+		"schema":    checkSchema(),
+		"templates": checkTemplates(),
+	}
+	if shuttingDown.Load() {
+		checks["shutdown"] = errors.New("shutting down")
+	} else {
+		checks["shutdown"] = nil
+	}
+	response := ReadinessResponse{Status: "ok", Checks: map[string]string{}}
+	status := http.StatusOK
+	for name, err := range checks {
+		if err != nil {
+			response.Checks[name] = err.Error()
+			response.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		} else {
+			response.Checks[name] = "ok"
+		}
+	}
+	writeHealth(w, status, response)
+}
+
+// checkSchema returns an error if the schema version of the database isn't the forum's
+func checkSchema() error {
+	version, err := databaseAPI.GetSchemaVersion(database)
+	if err != nil {
+		return err
+	}
+	if version != databaseAPI.SchemaVersion {
+		return errors.New("schema version " + strconv.Itoa(version) + ", expected " + strconv.Itoa(databaseAPI.SchemaVersion))
+	}
+	return nil
+}
+
+// writeHealth writes the JSON response of a health check, which must never be cached
+func writeHealth(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,55 @@
+package webAPI
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown renders posts and comments. Raw HTML in the source is never emitted by goldmark
+// (WithUnsafe is not set), the sanitizer below is a second line of defense.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		highlighting.NewHighlighting(
+			highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+		),
+	),
+	goldmark.WithRendererOptions(html.WithHardWraps()),
+)
+
+// renderMarkdown converts the markdown source of a post or comment to sanitized HTML
+func renderMarkdown(source string) template.HTML {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return template.HTML("<p>" + template.HTMLEscapeString(source) + "</p>")
+	}
+	return template.HTML(sanitizeHTML(buf.String()))
+}
+
+// PreviewApi renders the submitted content as it will be displayed once posted
+func PreviewApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		writeStatus(w, r, http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeStatus(w, r, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(renderMarkdown(r.FormValue("content"))))
+}
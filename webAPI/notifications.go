@@ -0,0 +1,245 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	notificationComment   = "comment"
+	notificationReply     = "reply"
+	notificationMention   = "mention"
+	notificationMilestone = "milestone"
+	notificationThread    = "thread"
+	notificationAuthor    = "author"
+	notificationsPerPage  = 50
+)
+
+type NotificationsPage struct {
+	User          User
+	Notifications []databaseAPI.Notification
+}
+
+// voteMilestones are the numbers of upvotes of a post that notify its author
+var voteMilestones = []int{10, 50, 100, 500, 1000}
+
+// mentionPattern matches @username, the character before the @ excludes email addresses
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w(?:[\w.-]*\w)?)`)
+
+// parseMentions returns the usernames mentioned in a content, without duplicates
+func parseMentions(content string) []string {
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		if !inArray(match[1], usernames) {
+			usernames = append(usernames, match[1])
+		}
+	}
+	return usernames
+}
+
+// notify saves a notification, users are never notified of their own actions nor of the kinds they muted
+func notify(notification databaseAPI.Notification) {
+	if notification.Username == "" || notification.Username == notification.Actor {
+		return
+	}
+	if settings, err := databaseAPI.GetUserSettings(database, notification.Username); err == nil && inArray(notification.Kind, settings.MutedNotifications) {
+		return
+	}
+	id, err := databaseAPI.AddNotification(database, notification, time.Now())
+	if err != nil {
+		logger.Error("notification failed", "username", notification.Username, "err", err)
+		return
+	}
+	notification.Id = id
+	publishNotification(notification)
+	if notification.Kind == notificationReply || notification.Kind == notificationComment {
+		queueReplyEmail(notification)
+	}
+}
+
+// notifyComment notifies the author of the replied comment, the author of the post, the mentioned users and the
+// followers of the thread of a new comment
+func notifyComment(post databaseAPI.Post, comment databaseAPI.Comment) {
+	// each user gets a single notification per comment, the most specific one
+	notified := map[string]bool{comment.Username: true}
+	if comment.ParentId != 0 {
+		parent, err := store.GetComment(comment.ParentId)
+		if err != nil {
+			logger.Error("reply notification failed", "comment_id", comment.Id, "err", err)
+		} else if !notified[parent.Username] {
+			notified[parent.Username] = true
+			notify(databaseAPI.Notification{
+				Username:  parent.Username,
+				Kind:      notificationReply,
+				Actor:     comment.Username,
+				PostId:    post.Id,
+				CommentId: comment.Id,
+				Message:   comment.Username + " replied to your comment on \"" + post.Title + "\"",
+			})
+		}
+	}
+	if !notified[post.Username] {
+		notified[post.Username] = true
+		notify(databaseAPI.Notification{
+			Username:  post.Username,
+			Kind:      notificationComment,
+			Actor:     comment.Username,
+			PostId:    post.Id,
+			CommentId: comment.Id,
+			Message:   comment.Username + " commented on your post \"" + post.Title + "\"",
+		})
+	}
+	notifyMentions(comment.Content, comment.Username, post, comment.Id, notified)
+	followers, err := databaseAPI.GetFollowers(database, subscriptionThread, strconv.Itoa(post.Id))
+	if err != nil {
+		logger.Error("thread notifications failed", "post_id", post.Id, "err", err)
+		return
+	}
+	for _, username := range followers {
+		if notified[username] {
+			continue
+		}
+		notified[username] = true
+		notify(databaseAPI.Notification{
+			Username:  username,
+			Kind:      notificationThread,
+			Actor:     comment.Username,
+			PostId:    post.Id,
+			CommentId: comment.Id,
+			Message:   comment.Username + " commented on \"" + post.Title + "\"",
+		})
+	}
+}
+
+// notifyFollowers notifies the followers of the author of a new post, except the users already notified
+func notifyFollowers(post databaseAPI.Post, notified map[string]bool) {
+	followers, err := databaseAPI.GetFollowers(database, subscriptionUser, post.Username)
+	if err != nil {
+		logger.Error("follower notifications failed", "post_id", post.Id, "err", err)
+		return
+	}
+	for _, username := range followers {
+		if notified[username] {
+			continue
+		}
+		notified[username] = true
+		notify(databaseAPI.Notification{
+			Username: username,
+			Kind:     notificationAuthor,
+			Actor:    post.Username,
+			PostId:   post.Id,
+			Message:  post.Username + " published \"" + post.Title + "\"",
+		})
+	}
+}
+
+// notifyMentions notifies the users mentioned in a post or a comment, except the ones already notified
+func notifyMentions(content string, actor string, post databaseAPI.Post, commentId int, notified map[string]bool) {
+	for _, username := range parseMentions(content) {
+		if notified[username] {
+			continue
+		}
+		free, err := store.UsernameNotTaken(username)
+		if err != nil {
+			logger.Error("mention notification failed", "post_id", post.Id, "err", err)
+			continue
+		}
+		if free {
+			continue
+		}
+		notified[username] = true
+		notify(databaseAPI.Notification{
+			Username:  username,
+			Kind:      notificationMention,
+			Actor:     actor,
+			PostId:    post.Id,
+			CommentId: commentId,
+			Message:   actor + " mentioned you in \"" + post.Title + "\"",
+		})
+	}
+}
+
+// notifyVoteMilestone notifies the author of a post when its upvotes reach a milestone for the first time
+func notifyVoteMilestone(postId int) {
+	post, err := store.GetPost(strconv.Itoa(postId))
+	if err != nil {
+		logger.Error("milestone notification failed", "post_id", postId, "err", err)
+		return
+	}
+	for _, milestone := range voteMilestones {
+		if post.UpVotes != milestone {
+			continue
+		}
+		message := fmt.Sprintf("Your post \"%s\" reached %d upvotes", post.Title, milestone)
+		exists, err := databaseAPI.NotificationExists(database, post.Username, notificationMilestone, postId, message)
+		if err != nil || exists {
+			return
+		}
+		notify(databaseAPI.Notification{
+			Username: post.Username,
+			Kind:     notificationMilestone,
+			PostId:   postId,
+			Message:  message,
+		})
+	}
+}
+
+// DisplayNotifications displays the notifications of the user
+func DisplayNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	payload := NotificationsPage{User: getCurrentUser(r)}
+	notifications, err := databaseAPI.GetNotifications(database, payload.User.Username, notificationsPerPage)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	payload.Notifications = notifications
+	renderTemplate(w, r, "notifications.html", payload)
+}
+
+// ReadNotificationsApi marks the notification with the given id, or all of them with all=1, as read
+func ReadNotificationsApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		writeStatus(w, r, http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if r.FormValue("all") == "1" {
+		err = databaseAPI.MarkAllNotificationsRead(database, username)
+	} else {
+		id, convErr := strconv.Atoi(r.FormValue("id"))
+		if convErr != nil {
+			renderError(w, r, http.StatusBadRequest, "Invalid notification")
+			return
+		}
+		err = databaseAPI.MarkNotificationRead(database, username, id)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/notifications", http.StatusFound)
+}
@@ -0,0 +1,131 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"FORUM-GO/logAPI"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxReportLength = 500
+
+type ReportsPage struct {
+	User    User
+	Reports []databaseAPI.Report
+}
+
+// ReportsApi reports a post, or one of its comments with commentId, to the admins
+func ReportsApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	post, err := store.GetPost(r.FormValue("postId"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	commentId, _ := strconv.Atoi(r.FormValue("commentId"))
+	if commentId != 0 {
+		comment, err := store.GetComment(commentId)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if comment.PostId != post.Id {
+			renderError(w, r, http.StatusBadRequest, "Invalid comment")
+			return
+		}
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" || utf8.RuneCountInString(reason) > maxReportLength {
+		renderError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid reason, it must have between 1 and %d characters", maxReportLength))
+		return
+	}
+	now := time.Now()
+	id, err := databaseAPI.AddReport(database, username, post.Id, commentId, reason, now)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	logAPI.Audit(r.Context(), logger, logAPI.ReportOpened{Username: username, PostId: post.Id})
+	fireWebhooks(eventReportOpened, username, post.Id, post.Categories, ReportPayload{
+		Id:        id,
+		PostId:    post.Id,
+		CommentId: commentId,
+		Username:  username,
+		Reason:    reason,
+		URL:       postURL(post.Id, commentId),
+	})
+	http.Redirect(w, r, "/post?id="+strconv.Itoa(post.Id), http.StatusFound)
+}
+
+// DisplayReports displays the open reports to the admins
+func DisplayReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if !isAdmin(r) {
+		writeStatus(w, r, http.StatusForbidden)
+		return
+	}
+	payload := ReportsPage{User: getCurrentUser(r)}
+	var err error
+	if payload.Reports, err = databaseAPI.GetReports(database, "open"); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	renderTemplate(w, r, "reports.html", payload)
+}
+
+// CloseReportApi marks a report as handled
+func CloseReportApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if !isAdmin(r) {
+		writeStatus(w, r, http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid report")
+		return
+	}
+	if err := databaseAPI.CloseReport(database, id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/reports", http.StatusFound)
+}
@@ -0,0 +1,172 @@
+package webAPI
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags maps every tag allowed in rendered content to the attributes it may keep
+var allowedTags = map[string][]string{
+	"a":          {"href", "title"},
+	"blockquote": {},
+	"br":         {},
+	"code":       {"class"},
+	"del":        {},
+	"em":         {},
+	"h1":         {},
+	"h2":         {},
+	"h3":         {},
+	"h4":         {},
+	"h5":         {},
+	"h6":         {},
+	"hr":         {},
+	"img":        {"src", "alt", "title"},
+	"input":      {"checked"},
+	"li":         {},
+	"ol":         {"start"},
+	"p":          {},
+	"pre":        {"class"},
+	"span":       {"class"},
+	"strong":     {},
+	"table":      {},
+	"tbody":      {},
+	"td":         {"align"},
+	"th":         {"align"},
+	"thead":      {},
+	"tr":         {},
+	"ul":         {},
+}
+
+// voidTags are the allowed tags that have no end tag
+var voidTags = map[string]bool{
+	"br":    true,
+	"hr":    true,
+	"img":   true,
+	"input": true,
+}
+
+// droppedTags are removed together with everything they contain
+var droppedTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"textarea": true,
+	"title":    true,
+}
+
+// allowedSchemes are the URL schemes accepted in href and src attributes, relative URLs are always accepted
+var allowedSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+var safeAttributeValue = regexp.MustCompile(`^[a-zA-Z0-9 _-]*$`)
+
+// sanitizeHTML strips every tag, attribute and URL that is not in the allowlist. The tags left open are closed, so
+// that the content can't change the rest of the page.
+func sanitizeHTML(input string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(input))
+	var output strings.Builder
+	dropDepth := 0
+	var open []string
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			closeTags(&output, open)
+			return output.String()
+		case html.TextToken:
+			if dropDepth == 0 {
+				output.WriteString(html.EscapeString(string(tokenizer.Text())))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if droppedTags[token.Data] {
+				if tokenType == html.StartTagToken {
+					dropDepth++
+				}
+				continue
+			}
+			attributes, ok := allowedTags[token.Data]
+			if !ok || dropDepth > 0 {
+				continue
+			}
+			output.WriteString("<" + token.Data)
+			for _, attribute := range token.Attr {
+				if value, ok := sanitizeAttribute(attribute, attributes); ok {
+					output.WriteString(" " + attribute.Key + `="` + html.EscapeString(value) + `"`)
+				}
+			}
+			switch token.Data {
+			case "a":
+				output.WriteString(` rel="nofollow noopener noreferrer"`)
+			case "input":
+				// only the read-only checkboxes of task lists are rendered
+				output.WriteString(` type="checkbox" disabled`)
+			}
+			output.WriteString(">")
+			if !voidTags[token.Data] {
+				open = append(open, token.Data)
+			}
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			if droppedTags[token.Data] {
+				if dropDepth > 0 {
+					dropDepth--
+				}
+				continue
+			}
+			if dropDepth > 0 {
+				continue
+			}
+			// an end tag closes the tags opened since its start tag, and is removed without start tag
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == token.Data {
+					closeTags(&output, open[i:])
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+}
+
+// closeTags writes the end tags of open tags, the last opened first
+func closeTags(output *strings.Builder, open []string) {
+	for i := len(open) - 1; i >= 0; i-- {
+		output.WriteString("</" + open[i] + ">")
+	}
+}
+
+// sanitizeAttribute returns the value to keep for an attribute, or false if it must be removed
+func sanitizeAttribute(attribute html.Attribute, allowed []string) (string, bool) {
+	if attribute.Namespace != "" || !inArray(attribute.Key, allowed) {
+		return "", false
+	}
+	switch attribute.Key {
+	case "href", "src":
+		return attribute.Val, isSafeURL(attribute.Val)
+	case "alt", "title":
+		return attribute.Val, true
+	case "checked":
+		return "", true
+	}
+	return attribute.Val, safeAttributeValue.MatchString(attribute.Val)
+}
+
+// isSafeURL returns true if the URL is relative or uses an allowed scheme
+func isSafeURL(raw string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	if parsed.Scheme == "" {
+		return !strings.HasPrefix(strings.TrimSpace(raw), "//")
+	}
+	return allowedSchemes[strings.ToLower(parsed.Scheme)]
+}
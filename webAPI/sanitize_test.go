@@ -0,0 +1,70 @@
+package webAPI
+
+import (
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"text is escaped", `1 < 2 & 3 > 2`, `1 &lt; 2 &amp; 3 &gt; 2`},
+		{"allowed tags are kept", `<p><strong>bold</strong> <em>em</em></p>`, `<p><strong>bold</strong> <em>em</em></p>`},
+		{"unknown tags keep their text", `<div><u>text</u></div>`, `text`},
+		{"comments are removed", `<!-- <script>alert(1)</script> -->text`, `text`},
+
+		{"http link", `<a href="https://example.com/?a=1&b=2" title="t">x</a>`, `<a href="https://example.com/?a=1&amp;b=2" title="t" rel="nofollow noopener noreferrer">x</a>`},
+		{"relative link", `<a href="/post?id=1">x</a>`, `<a href="/post?id=1" rel="nofollow noopener noreferrer">x</a>`},
+		{"mailto link", `<a href="mailto:a@example.com">x</a>`, `<a href="mailto:a@example.com" rel="nofollow noopener noreferrer">x</a>`},
+		{"protocol-relative link", `<a href="//evil.example/">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript link", `<a href="javascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"uppercase javascript link", `<a href="JavaScript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript link after spaces", `<a href="  javascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"vbscript link", `<a href="vbscript:msgbox(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"data image", `<img src="data:image/svg+xml;base64,PHN2Zz4=" alt="a">`, `<img alt="a">`},
+		{"data link", `<a href="data:text/html,<script>alert(1)</script>">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+
+		{"decimal entity scheme", `<a href="&#106;avascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"hexadecimal entity scheme", `<a href="&#x6A;avascript&#x3A;alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"named entity colon", `<a href="javascript&colon;alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"entity tab in scheme", `<a href="java&#x09;script:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"entity newline in scheme", `<a href="java&#10;script:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+
+		{"event handler on img", `<img src="x.png" onerror="alert(1)">`, `<img src="x.png">`},
+		{"event handler on p", `<p onclick="alert(1)">x</p>`, `<p>x</p>`},
+		{"uppercase event handler", `<span class="x" ONMOUSEOVER="alert(1)">x</span>`, `<span class="x">x</span>`},
+		{"style attribute", `<p style="position:fixed">x</p>`, `<p>x</p>`},
+		{"class breaking out of its attribute", `<span class="x&quot; onclick=&quot;alert(1)">x</span>`, `<span>x</span>`},
+		{"quotes in a title", `<a href="/" title="&quot;><script>alert(1)</script>">x</a>`, `<a href="/" title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;" rel="nofollow noopener noreferrer">x</a>`},
+		{"text input", `<input type="text" value="x" checked>`, `<input checked="" type="checkbox" disabled>`},
+
+		{"script", `<script>alert(1)</script>after`, `after`},
+		{"uppercase script", `<SCRIPT>alert(1)</SCRIPT>after`, `after`},
+		{"script with attributes", `<script src="https://evil.example/x.js"></script>after`, `after`},
+		{"style", `<style>body{display:none}</style>after`, `after`},
+		{"iframe", `<iframe src="https://evil.example/"></iframe>after`, `after`},
+		{"svg", `<svg onload="alert(1)"><p>x</p></svg>`, `<p>x</p>`},
+
+		{"unclosed script", `before<script>alert(1)`, `before`},
+		{"unclosed tags", `<p>unclosed <em>tags`, `<p>unclosed <em>tags</em></p>`},
+		{"unclosed link", `<a href="/">link`, `<a href="/" rel="nofollow noopener noreferrer">link</a>`},
+		{"unclosed table", `<table><tr><td>x`, `<table><tr><td>x</td></tr></table>`},
+		{"end tag without start tag", `x</p></a>y`, `xy`},
+		{"misnested tags", `<em><strong>x</em>y</strong>`, `<em><strong>x</strong></em>y`},
+		{"nested lists", `<ul><li>a<ul><li>b</li></ul></li></ul>`, `<ul><li>a<ul><li>b</li></ul></li></ul>`},
+		{"void tags", `a<br>b<hr><img src="/x.png">`, `a<br>b<hr><img src="/x.png">`},
+		{"self-closing tag", `<p/>x`, `<p>x</p>`},
+		{"script nested in a script", `<script><script>alert(1)</script>after</script>`, `after`},
+		{"script in an allowed tag", `<p>a<script>alert(1)</script>b</p>`, `<p>ab</p>`},
+		{"allowed tag in a dropped tag", `<style><p>x</p></style>after`, `after`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sanitizeHTML(test.input); got != test.want {
+				t.Errorf("sanitizeHTML(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"FORUM-GO/logAPI"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	// the time zones of the users are available on servers without a time zone database
+	_ "time/tzdata"
+	"unicode/utf8"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	sortOldest         = "oldest"
+	sortNewest         = "newest"
+	sortTop            = "top"
+	themeLight         = "light"
+	themeDark          = "dark"
+	themeSystem        = "system"
+	displayNameMaxSize = 50
+	bioMaxSize         = 500
+)
+
+type SettingsPage struct {
+	User              User
+	Settings          databaseAPI.UserSettings
+	Email             string
+	Timezones         []string
+	PostsPerPage      []int
+	NotificationKinds []NotificationKind
+	Message           string
+	Error             string
+}
+
+// NotificationKind is a kind of notification that users can mute, with its description
+type NotificationKind struct {
+	Kind        string
+	Description string
+	Muted       bool
+}
+
+// notificationKinds are the kinds of notifications shown on the settings page
+var notificationKinds = []NotificationKind{
+	{Kind: notificationComment, Description: "Comments on my posts"},
+	{Kind: notificationReply, Description: "Replies to my comments"},
+	{Kind: notificationMention, Description: "Mentions of my username"},
+	{Kind: notificationMilestone, Description: "Upvote milestones of my posts"},
+	{Kind: notificationThread, Description: "Comments in the threads I follow"},
+	{Kind: notificationAuthor, Description: "Posts of the users I follow"},
+}
+
+// timezones are the time zones suggested on the settings page, any time zone of the IANA database can be typed
+var timezones = []string{
+	"UTC", "Europe/London", "Europe/Paris", "Europe/Berlin", "Europe/Moscow", "Africa/Cairo", "Africa/Johannesburg",
+	"America/New_York", "America/Chicago", "America/Denver", "America/Los_Angeles", "America/Sao_Paulo",
+	"Asia/Dubai", "Asia/Kolkata", "Asia/Shanghai", "Asia/Singapore", "Asia/Tokyo", "Australia/Sydney",
+	"Pacific/Auckland",
+}
+
+// postsPerPageChoices are the numbers of posts per page users can choose
+var postsPerPageChoices = []int{10, 20, 50, 100}
+
+// settingsMessages are the messages of the settings page, by the value of its saved and err parameters
+var settingsMessages = map[string]string{
+	"settings":          "Settings saved",
+	"email":             "Email changed",
+	"password":          "Password changed",
+	"invalid_password":  "Invalid password",
+	"invalid_email":     "Invalid email",
+	"email_taken":       "Email already taken",
+	"password_mismatch": "The new passwords don't match",
+	"invalid_settings":  "Invalid settings",
+	"invalid_timezone":  "Unknown time zone",
+}
+
+// userLocation returns the time zone of a user, the one of the server if they didn't choose one
+func userLocation(settings databaseAPI.UserSettings) *time.Location {
+	if settings.Timezone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// sortPosts sorts posts in the order of a sort setting
+func sortPosts(posts []databaseAPI.Post, order string) {
+	switch order {
+	case sortNewest:
+		sort.SliceStable(posts, func(i, j int) bool { return posts[i].Id > posts[j].Id })
+	case sortTop:
+		sort.SliceStable(posts, func(i, j int) bool {
+			return posts[i].UpVotes-posts[i].DownVotes > posts[j].UpVotes-posts[j].DownVotes
+		})
+	default:
+		sort.SliceStable(posts, func(i, j int) bool { return posts[i].Id < posts[j].Id })
+	}
+}
+
+// checkPassword returns true if a password is the one of a user
+func checkPassword(username string, password string) (bool, error) {
+	email, err := store.GetUserEmail(username)
+	if err != nil {
+		return false, err
+	}
+	_, _, hash, err := store.GetUserInfo(email)
+	if err != nil {
+		return false, err
+	}
+	return databaseAPI.CompareHashAndPassword(hash, password) == nil, nil
+}
+
+// DisplaySettings displays the settings of the user, with the forms to change their email and their password
+func DisplaySettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	payload := SettingsPage{User: getCurrentUser(r), Timezones: timezones, PostsPerPage: postsPerPageChoices}
+	payload.Settings = payload.User.Settings
+	email, err := store.GetUserEmail(payload.User.Username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	payload.Email = email
+	for _, kind := range notificationKinds {
+		kind.Muted = inArray(kind.Kind, payload.Settings.MutedNotifications)
+		payload.NotificationKinds = append(payload.NotificationKinds, kind)
+	}
+	payload.Message = settingsMessages[r.URL.Query().Get("saved")]
+	payload.Error = settingsMessages[r.URL.Query().Get("err")]
+	renderTemplate(w, r, "settings.html", payload)
+}
+
+// SettingsApi saves the settings of the user
+func SettingsApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	settings := databaseAPI.UserSettings{
+		DisplayName: strings.TrimSpace(r.FormValue("display_name")),
+		Bio:         strings.TrimSpace(r.FormValue("bio")),
+		Timezone:    r.FormValue("timezone"),
+		Theme:       r.FormValue("theme"),
+		DefaultSort: r.FormValue("default_sort"),
+	}
+	settings.PostsPerPage, _ = strconv.Atoi(r.FormValue("posts_per_page"))
+	validPostsPerPage := false
+	for _, choice := range postsPerPageChoices {
+		validPostsPerPage = validPostsPerPage || settings.PostsPerPage == choice
+	}
+	if !validPostsPerPage || utf8.RuneCountInString(settings.DisplayName) > displayNameMaxSize ||
+		utf8.RuneCountInString(settings.Bio) > bioMaxSize ||
+		!inArray(settings.Theme, []string{themeLight, themeDark, themeSystem}) ||
+		!inArray(settings.DefaultSort, []string{sortOldest, sortNewest, sortTop}) {
+		http.Redirect(w, r, "/settings?err=invalid_settings", http.StatusFound)
+		return
+	}
+	if settings.Timezone != "" {
+		if _, err := time.LoadLocation(settings.Timezone); err != nil {
+			http.Redirect(w, r, "/settings?err=invalid_timezone", http.StatusFound)
+			return
+		}
+	}
+	for _, kind := range notificationKinds {
+		if r.FormValue("notify_"+kind.Kind) != "1" {
+			settings.MutedNotifications = append(settings.MutedNotifications, kind.Kind)
+		}
+	}
+	if err := databaseAPI.SetUserSettings(database, username, settings); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/settings?saved=settings", http.StatusFound)
+}
+
+// SettingsEmailApi changes the email of the user after checking their password
+func SettingsEmailApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	valid, err := checkPassword(username, r.FormValue("password"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !valid {
+		http.Redirect(w, r, "/settings?err=invalid_password", http.StatusFound)
+		return
+	}
+	email := strings.TrimSpace(r.FormValue("email"))
+	if email == "" || !strings.Contains(email, "@") {
+		http.Redirect(w, r, "/settings?err=invalid_email", http.StatusFound)
+		return
+	}
+	if err := databaseAPI.SetEmail(database, username, email); err != nil {
+		if errors.Is(err, databaseAPI.ErrConflict) {
+			http.Redirect(w, r, "/settings?err=email_taken", http.StatusFound)
+			return
+		}
+		writeError(w, r, err)
+		return
+	}
+	logAPI.Audit(r.Context(), logger, logAPI.EmailChanged{Username: username, Email: email})
+	http.Redirect(w, r, "/settings?saved=email", http.StatusFound)
+}
+
+// SettingsPasswordApi changes the password of the user after checking their current one. The other sessions of the
+// user end, this one gets a new cookie.
+func SettingsPasswordApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	valid, err := checkPassword(username, r.FormValue("password"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !valid {
+		http.Redirect(w, r, "/settings?err=invalid_password", http.StatusFound)
+		return
+	}
+	newPassword := r.FormValue("new_password")
+	if newPassword == "" || newPassword != r.FormValue("confirm_password") {
+		http.Redirect(w, r, "/settings?err=password_mismatch", http.StatusFound)
+		return
+	}
+	if err := databaseAPI.SetPassword(database, username, newPassword); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	email, err := store.GetUserEmail(username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	expiration := time.Now().Add(sessionLength)
+	value := uuid.NewV4().String()
+	if err := store.UpdateCookie(value, expiration, email); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: "SESSION", Value: value, Expires: expiration, Path: "/"})
+	logAPI.Audit(r.Context(), logger, logAPI.PasswordChanged{Username: username})
+	http.Redirect(w, r, "/settings?saved=password", http.StatusFound)
+}
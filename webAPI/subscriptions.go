@@ -0,0 +1,145 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	subscriptionCategory = "category"
+	subscriptionUser     = "user"
+	subscriptionThread   = "thread"
+	feedLength           = 50
+)
+
+type FeedPage struct {
+	User       User
+	Items      []databaseAPI.FeedItem
+	Categories []string
+	Users      []string
+	Threads    []databaseAPI.Post
+}
+
+// SubscriptionsApi follows the target with follow=1 and unfollows it otherwise
+func SubscriptionsApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	kind := r.FormValue("kind")
+	target := r.FormValue("target")
+	valid, err := isValidSubscription(username, kind, target)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !valid {
+		renderError(w, r, http.StatusBadRequest, "Invalid subscription")
+		return
+	}
+	if r.FormValue("follow") == "1" {
+		err = databaseAPI.Follow(database, username, kind, target, time.Now())
+	} else {
+		err = databaseAPI.Unfollow(database, username, kind, target)
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, localRedirect(r.FormValue("redirect")), http.StatusFound)
+}
+
+// isValidSubscription returns true if the target exists and can be followed by the user
+func isValidSubscription(username string, kind string, target string) (bool, error) {
+	switch kind {
+	case subscriptionCategory:
+		categories, err := store.GetCategories()
+		return inArray(target, categories), err
+	case subscriptionUser:
+		if target == username {
+			return false, nil
+		}
+		free, err := store.UsernameNotTaken(target)
+		return !free, err
+	case subscriptionThread:
+		_, err := store.GetPost(target)
+		if errors.Is(err, databaseAPI.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, nil
+}
+
+// DisplayFeed displays the latest posts and comments from what the user follows
+func DisplayFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	payload := FeedPage{User: getCurrentUser(r)}
+	var err error
+	if payload.Items, err = databaseAPI.GetFeed(database, payload.User.Username, feedLength); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if payload.Categories, err = databaseAPI.GetFollowed(database, payload.User.Username, subscriptionCategory); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if payload.Users, err = databaseAPI.GetFollowed(database, payload.User.Username, subscriptionUser); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	threads, err := databaseAPI.GetFollowed(database, payload.User.Username, subscriptionThread)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	for _, id := range threads {
+		post, err := store.GetPost(id)
+		// the followed threads which have been deleted are skipped
+		if errors.Is(err, databaseAPI.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		payload.Threads = append(payload.Threads, post)
+	}
+	renderTemplate(w, r, "feed.html", payload)
+}
+
+// getFollowingPosts returns the posts of the feed of a user, ordered by their latest activity
+func getFollowingPosts(username string) ([]databaseAPI.Post, error) {
+	return databaseAPI.GetFeedPosts(database, username, feedLength)
+}
+
+// localRedirect returns the path if it stays on the forum, "/" otherwise
+func localRedirect(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,270 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"sync/atomic"
+	texttemplate "text/template"
+	"time"
+)
+
+// templateWatchInterval is the interval at which the template files are checked for changes in development mode
+const templateWatchInterval = time.Second
+
+// templateFuncs are the helper functions available in every template
+var templateFuncs = template.FuncMap{
+	"markdown":     renderMarkdown,
+	"relativeTime": relativeTime,
+	"localTime":    localTime,
+	"pluralize":    pluralize,
+	"asset":        assetPath,
+}
+
+// templateSet holds the parsed templates of the pages, by file name, and of the emails
+type templateSet struct {
+	pages      map[string]*template.Template
+	textEmails *texttemplate.Template
+	htmlEmails *template.Template
+	stamp      templateStamp
+}
+
+// templateStamp identifies the state of the template files, it changes when a file is changed, added or removed
+type templateStamp struct {
+	latest time.Time
+	files  int
+}
+
+// templates holds the templates, parsed once by LoadTemplates and replaced by WatchTemplates in development mode
+var templates atomic.Pointer[templateSet]
+
+// LoadTemplates parses the templates of the public file system: the pages of HTML, each one with the layouts of
+// HTML/layouts and the partials of HTML/partials, and the emails of MAIL
+func LoadTemplates() error {
+	set, err := parseTemplates(publicFS)
+	if err != nil {
+		return err
+	}
+	templates.Store(set)
+	return nil
+}
+
+// WatchTemplates parses the templates again when their files change, until the context is canceled. Templates that
+// don't parse are logged and the previous ones are kept.
+func WatchTemplates(ctx context.Context) {
+	ticker := time.NewTicker(templateWatchInterval)
+	defer ticker.Stop()
+	var seen templateStamp
+	if set := templates.Load(); set != nil {
+		seen = set.stamp
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		stamp, err := readTemplateStamp(publicFS)
+		if err != nil || stamp == seen {
+			continue
+		}
+		// a failed parse isn't tried again before the next change
+		seen = stamp
+		set, err := parseTemplates(publicFS)
+		if err != nil {
+			logger.Error("unable to reload the templates, the previous ones are kept", "err", err)
+			continue
+		}
+		templates.Store(set)
+		logger.Info("templates reloaded", "pages", len(set.pages))
+	}
+}
+
+// parseTemplates parses the templates of a public file system
+func parseTemplates(fsys fs.FS) (*templateSet, error) {
+	// the stamp is read first, so that a change made during the parsing is seen at the next check
+	stamp, err := readTemplateStamp(fsys)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := template.New("").Funcs(templateFuncs).ParseFS(fsys, "HTML/layouts/*.html", "HTML/partials/*.html")
+	if err != nil {
+		return nil, err
+	}
+	files, err := fs.Glob(fsys, "HTML/*.html")
+	if err != nil {
+		return nil, err
+	}
+	set := &templateSet{pages: map[string]*template.Template{}, stamp: stamp}
+	// every page is parsed in its own copy of the layouts, so that the pages define the blocks of the layouts
+	// without overriding each other's
+	for _, file := range files {
+		page, err := shared.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := page.ParseFS(fsys, file); err != nil {
+			return nil, err
+		}
+		name := path.Base(file)
+		set.pages[name] = page.Lookup(name)
+	}
+	if set.pages["error.html"] == nil {
+		return nil, errors.New("no HTML/error.html template")
+	}
+	if set.textEmails, err = texttemplate.ParseFS(fsys, "MAIL/*.txt"); err != nil {
+		return nil, err
+	}
+	if set.htmlEmails, err = template.ParseFS(fsys, "MAIL/*.html"); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// readTemplateStamp returns the stamp of the template files of a public file system
+func readTemplateStamp(fsys fs.FS) (templateStamp, error) {
+	var stamp templateStamp
+	for _, dir := range []string{"HTML", "MAIL"} {
+		err := fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			stamp.files++
+			if info.ModTime().After(stamp.latest) {
+				stamp.latest = info.ModTime()
+			}
+			return nil
+		})
+		if err != nil {
+			return stamp, err
+		}
+	}
+	return stamp, nil
+}
+
+// assetPath returns the URL path of an asset, under its hashed name when the assets are loaded
+func assetPath(name string) string {
+	if assets == nil {
+		return "/public/" + name
+	}
+	return assets.Path(name)
+}
+
+// checkTemplates returns an error if the templates aren't loaded
+func checkTemplates() error {
+	if templates.Load() == nil {
+		return errors.New("templates not loaded")
+	}
+	return nil
+}
+
+// executeTemplate executes the template of a page
+func executeTemplate(w io.Writer, name string, data interface{}) error {
+	set := templates.Load()
+	if set == nil {
+		return errors.New("templates not loaded")
+	}
+	page, ok := set.pages[name]
+	if !ok {
+		return errors.New("no template " + name)
+	}
+	return page.Execute(w, data)
+}
+
+// renderTemplate renders a page
+func renderTemplate(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	renderTemplateStatus(w, r, http.StatusOK, name, data)
+}
+
+// renderTemplateStatus renders a page with a status. The page is rendered in a buffer first, so that a failing
+// template renders the error page rather than half a page.
+func renderTemplateStatus(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) {
+	var page bytes.Buffer
+	if err := executeTemplate(&page, name, data); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	page.WriteTo(w)
+}
+
+// templateTime returns the time of a value passed to a template, a time.Time or a time saved in the database
+func templateTime(value interface{}) (time.Time, bool) {
+	switch value := value.(type) {
+	case time.Time:
+		return value, !value.IsZero()
+	case string:
+		t, err := databaseAPI.ParseTime(value)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// relativeTime returns how long ago a time is, as "5 minutes ago", or how long until it, as "in 2 hours", and its
+// date in the time zone of a user when it is more than a month away
+func relativeTime(user User, value interface{}) string {
+	t, ok := templateTime(value)
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	if user.location != nil {
+		t = t.In(user.location)
+	}
+	elapsed := time.Since(t)
+	future := elapsed < 0
+	if future {
+		elapsed = -elapsed
+	}
+	var amount string
+	switch {
+	case elapsed < time.Minute && future:
+		return "in less than a minute"
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		amount = pluralize(int(elapsed/time.Minute), "minute", "minutes")
+	case elapsed < 24*time.Hour:
+		amount = pluralize(int(elapsed/time.Hour), "hour", "hours")
+	case elapsed < 30*24*time.Hour:
+		amount = pluralize(int(elapsed/(24*time.Hour)), "day", "days")
+	default:
+		return t.Format("2 Jan 2006")
+	}
+	if future {
+		return "in " + amount
+	}
+	return amount + " ago"
+}
+
+// localTime returns a time in the time zone of a user, as "2 Jan 2006 15:04 CET"
+func localTime(user User, value interface{}) string {
+	t, ok := templateTime(value)
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	if user.location != nil {
+		t = t.In(user.location)
+	}
+	return t.Format("2 Jan 2006 15:04 MST")
+}
+
+// pluralize returns a count followed by the singular or the plural form of a word, as "1 comment" or "3 comments"
+func pluralize(count int, singular string, plural string) string {
+	if count == 1 || count == -1 {
+		return strconv.Itoa(count) + " " + singular
+	}
+	return strconv.Itoa(count) + " " + plural
+}
@@ -1,16 +1,25 @@
 package webAPI
 
 import (
+	"FORUM-GO/assetsAPI"
 	"FORUM-GO/databaseAPI"
 	"database/sql"
 	_ "github.com/mattn/go-sqlite3"
-	"html/template"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 )
 
 type User struct {
-	IsLoggedIn bool
-	Username   string
+	IsLoggedIn          bool
+	Username            string
+	UnreadNotifications int
+	IsAdmin             bool
+	Settings            databaseAPI.UserSettings
+	location            *time.Location
 }
 
 type HomePage struct {
@@ -21,15 +30,34 @@ type HomePage struct {
 }
 
 type PostsPage struct {
-	User  User
-	Title string
-	Posts []databaseAPI.Post
-	Icon  string
+	User        User
+	Title       string
+	Posts       []databaseAPI.Post
+	Icon        string
+	Category    string
+	Following   bool
+	Folders     []string
+	Folder      string
+	Page        int
+	Pages       int
+	PreviousURL string
+	NextURL     string
 }
 
 type PostPage struct {
+	User            User
+	Post            databaseAPI.Post
+	FollowingThread bool
+	FollowingAuthor bool
+	Bookmark        databaseAPI.Bookmark
+	Bookmarked      bool
+	Folders         []string
+	DisplayNames    map[string]string
+	AuthorBio       string
+}
+
+type NewPostPage struct {
 	User User
-	Post databaseAPI.Post
 }
 
 var database *sql.DB
@@ -38,53 +66,123 @@ func SetDatabase(db *sql.DB) {
 	database = db
 }
 
+// store holds the users, sessions, posts, comments, votes and categories
+var store databaseAPI.Store
+
+// SetStore sets the store of the users, sessions, posts, comments, votes and categories
+func SetStore(s databaseAPI.Store) {
+	store = s
+}
+
+// logger logs the events and the errors of the handlers and the background jobs
+var logger = slog.Default()
+
+// SetLogger sets the logger of the handlers and the background jobs
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
+// publicFS holds the templates, styles and scripts
+var publicFS fs.FS = os.DirFS("public")
+
+// assets serves the styles, scripts and images, linked by the templates
+var assets *assetsAPI.Assets
+
+// sessionLength is the time a user stays logged in
+var sessionLength = 31 * 24 * time.Hour
+
+// SetPublicFS sets the file system of the templates, styles and scripts
+func SetPublicFS(fsys fs.FS) {
+	publicFS = fsys
+}
+
+// SetAssets sets the assets linked by the templates
+func SetAssets(a *assetsAPI.Assets) {
+	assets = a
+}
+
+// SetSessionLength sets the time a user stays logged in
+func SetSessionLength(length time.Duration) {
+	sessionLength = length
+}
+
 // Index displays the Index page
 func Index(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
-		http.NotFound(w, r)
+		writeStatus(w, r, http.StatusNotFound)
 		return
 	}
-	if isLoggedIn(r) {
-		cookie, _ := r.Cookie("SESSION")
-		payload := HomePage{
-			User:              User{IsLoggedIn: true, Username: databaseAPI.GetUser(database, cookie.Value)},
-			Categories:        databaseAPI.GetCategories(database),
-			Icons:             databaseAPI.GetCategoriesIcons(database),
-			PostsByCategories: databaseAPI.GetPostsByCategories(database),
-		}
-		t, _ := template.ParseGlob("public/HTML/*.html")
-		t.ExecuteTemplate(w, "forum.html", payload)
+	payload := HomePage{User: getCurrentUser(r)}
+	var err error
+	if payload.Categories, err = store.GetCategories(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if payload.Icons, err = store.GetCategoriesIcons(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if payload.PostsByCategories, err = store.GetPostsByCategories(); err != nil {
+		writeError(w, r, err)
 		return
 	}
-	payload := HomePage{
-		User:              User{IsLoggedIn: false},
-		Categories:        databaseAPI.GetCategories(database),
-		Icons:             databaseAPI.GetCategoriesIcons(database),
-		PostsByCategories: databaseAPI.GetPostsByCategories(database),
+	for _, posts := range payload.PostsByCategories {
+		sortPosts(posts, payload.User.Settings.DefaultSort)
 	}
-	t, _ := template.ParseGlob("public/HTML/*.html")
-	t.ExecuteTemplate(w, "forum.html", payload)
+	renderTemplate(w, r, "forum.html", payload)
 	return
 }
 
 // DisplayPost displays a post on a template
 func DisplayPost(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeStatus(w, r, http.StatusMethodNotAllowed)
 		return
 	}
 	id := r.URL.Query().Get("id")
+	post, err := store.GetPost(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 	payload := PostPage{
-		Post: databaseAPI.GetPost(database, id),
+		Post: post,
 	}
-	if !isLoggedIn(r) {
-		payload.User = User{IsLoggedIn: false}
-	} else {
-		payload.User = User{IsLoggedIn: true}
+	payload.User = getCurrentUser(r)
+	if payload.User.IsLoggedIn {
+		payload.FollowingThread, _ = databaseAPI.IsFollowing(database, payload.User.Username, subscriptionThread, id)
+		payload.FollowingAuthor, _ = databaseAPI.IsFollowing(database, payload.User.Username, subscriptionUser, payload.Post.Username)
+		payload.Bookmark, err = databaseAPI.GetBookmark(database, payload.User.Username, payload.Post.Id)
+		payload.Bookmarked = err == nil
+		payload.Folders, _ = databaseAPI.GetBookmarkFolders(database, payload.User.Username)
+	}
+	if payload.Post.Comments, err = store.GetComments(id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	attachments, err := databaseAPI.GetAttachments(database, id)
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
-	payload.Post.Comments = databaseAPI.GetComments(database, id)
-	t, _ := template.ParseGlob("public/HTML/*.html")
-	t.ExecuteTemplate(w, "detail.html", payload)
+	payload.Post.Attachments = attachments
+	usernames := []string{payload.Post.Username}
+	for _, comment := range payload.Post.Comments {
+		if !inArray(comment.Username, usernames) {
+			usernames = append(usernames, comment.Username)
+		}
+	}
+	if payload.DisplayNames, err = databaseAPI.GetDisplayNames(database, usernames); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	authorSettings, err := databaseAPI.GetUserSettings(database, payload.Post.Username)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	payload.AuthorBio = authorSettings.Bio
+	renderTemplate(w, r, "detail.html", payload)
 }
 
 // GetPostsByApi GetPostByApi gets all post filtered by the given parameters
@@ -92,32 +190,49 @@ func GetPostsByApi(w http.ResponseWriter, r *http.Request) {
 	method := r.URL.Query().Get("by")
 	if method == "category" {
 		category := r.URL.Query().Get("category")
-		posts := databaseAPI.GetPostsByCategory(database, category)
+		icon, err := store.GetCategoryIcon(category)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		posts, err := store.GetPostsByCategory(category)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 		payload := PostsPage{
-			Title: "Posts in category " + category,
-			Posts: posts,
-			Icon:  databaseAPI.GetCategoryIcon(database, category),
+			User:     getCurrentUser(r),
+			Title:    "Posts in category " + category,
+			Posts:    posts,
+			Icon:     icon,
+			Category: category,
 		}
-		if isLoggedIn(r) {
-			payload.User = User{IsLoggedIn: true}
+		sortPosts(payload.Posts, payload.User.Settings.DefaultSort)
+		if payload.User.IsLoggedIn {
+			payload.Following, _ = databaseAPI.IsFollowing(database, payload.User.Username, subscriptionCategory, category)
 		}
-		t, _ := template.ParseGlob("public/HTML/*.html")
-		t.ExecuteTemplate(w, "posts.html", payload)
+		renderPosts(w, r, payload)
 		return
 	}
 	if method == "myposts" {
 		if isLoggedIn(r) {
-			cookie, _ := r.Cookie("SESSION")
-			username := databaseAPI.GetUser(database, cookie.Value)
-			posts := databaseAPI.GetPostsByUser(database, username)
+			username, err := sessionUser(r)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			posts, err := store.GetPostsByUser(username)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
 			payload := PostsPage{
-				User:  User{IsLoggedIn: true},
+				User:  getCurrentUser(r),
 				Title: "My posts",
 				Posts: posts,
 				Icon:  "fa-user",
 			}
-			t, _ := template.ParseGlob("public/HTML/*.html")
-			t.ExecuteTemplate(w, "posts.html", payload)
+			renderPosts(w, r, payload)
 			return
 		}
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
@@ -125,37 +240,129 @@ func GetPostsByApi(w http.ResponseWriter, r *http.Request) {
 	}
 	if method == "liked" {
 		if isLoggedIn(r) {
-			cookie, _ := r.Cookie("SESSION")
-			username := databaseAPI.GetUser(database, cookie.Value)
-			posts := databaseAPI.GetLikedPosts(database, username)
+			username, err := sessionUser(r)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			posts, err := store.GetLikedPosts(username)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
 			payload := PostsPage{
-				User:  User{IsLoggedIn: true},
+				User:  getCurrentUser(r),
 				Title: "Posts liked by me",
 				Posts: posts,
 				Icon:  "fa-heart",
 			}
-			t, _ := template.ParseGlob("public/HTML/*.html")
-			t.ExecuteTemplate(w, "posts.html", payload)
+			renderPosts(w, r, payload)
 			return
 		}
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	if method == "saved" {
+		if isLoggedIn(r) {
+			username, err := sessionUser(r)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			folder := r.URL.Query().Get("folder")
+			posts, err := databaseAPI.GetSavedPosts(database, username, folder)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			folders, err := databaseAPI.GetBookmarkFolders(database, username)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			payload := PostsPage{
+				User:    getCurrentUser(r),
+				Title:   "Posts saved by me",
+				Posts:   posts,
+				Icon:    "fa-bookmark",
+				Folders: folders,
+				Folder:  folder,
+			}
+			renderPosts(w, r, payload)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if method == "following" {
+		if isLoggedIn(r) {
+			username, err := sessionUser(r)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			posts, err := getFollowingPosts(username)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			payload := PostsPage{
+				User:  getCurrentUser(r),
+				Title: "Posts I follow",
+				Posts: posts,
+				Icon:  "fa-star",
+			}
+			renderPosts(w, r, payload)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	writeStatus(w, r, http.StatusNotFound)
+}
+
+// renderPosts renders a page of a list of posts, with the number of posts per page of the user
+func renderPosts(w http.ResponseWriter, r *http.Request, payload PostsPage) {
+	perPage := payload.User.Settings.PostsPerPage
+	if perPage <= 0 {
+		perPage = databaseAPI.DefaultUserSettings().PostsPerPage
+	}
+	payload.Pages = (len(payload.Posts) + perPage - 1) / perPage
+	payload.Page = 1
+	if page := r.URL.Query().Get("page"); page != "" {
+		var err error
+		if payload.Page, err = strconv.Atoi(page); err != nil || payload.Page < 1 || payload.Page > max(payload.Pages, 1) {
+			writeStatus(w, r, http.StatusNotFound)
+			return
+		}
+	}
+	start := min((payload.Page-1)*perPage, len(payload.Posts))
+	payload.Posts = payload.Posts[start:min(start+perPage, len(payload.Posts))]
+	pageURL := func(page int) string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		return r.URL.Path + "?" + query.Encode()
+	}
+	if payload.Page > 1 {
+		payload.PreviousURL = pageURL(payload.Page - 1)
+	}
+	if payload.Page < payload.Pages {
+		payload.NextURL = pageURL(payload.Page + 1)
+	}
+	renderTemplate(w, r, "posts.html", payload)
 }
 
 // NewPost displays the NewPost page
 func NewPost(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeStatus(w, r, http.StatusMethodNotAllowed)
 		return
 	}
 	if !isLoggedIn(r) {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
-	t, _ := template.ParseGlob("public/HTML/*.html")
-	t.ExecuteTemplate(w, "createThread.html", nil)
+	renderTemplate(w, r, "createThread.html", NewPostPage{User: getCurrentUser(r)})
 }
 
 // inArray check if a string is in an array
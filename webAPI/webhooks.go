@@ -0,0 +1,511 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"FORUM-GO/logAPI"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	eventPostCreated    = "post.created"
+	eventCommentCreated = "comment.created"
+	eventVoteCast       = "vote.cast"
+	eventReportOpened   = "report.opened"
+	webhookPollInterval = 10 * time.Second
+	webhookBatchSize    = 20
+	webhookLogLength    = 100
+	webhookMaxResponse  = 64 << 10
+	webhookMaxRetry     = 24 * time.Hour
+	deliveryDelivered   = "delivered"
+	deliveryDead        = "dead"
+)
+
+// webhookEvents are the events webhooks can subscribe to
+var webhookEvents = []string{eventPostCreated, eventCommentCreated, eventVoteCast, eventReportOpened}
+
+// HTTPClient sends the requests of the webhooks, *http.Client implements it
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// webhookMaxAttempts is the number of attempts of a delivery before it goes to the dead letters, the retries waiting
+// webhookRetryDelay doubled at each attempt
+var (
+	webhookTimeout     = 10 * time.Second
+	webhookMaxAttempts = 6
+	webhookRetryDelay  = 30 * time.Second
+)
+
+var webhookClient HTTPClient = &http.Client{Timeout: webhookTimeout}
+
+// webhookWakeUp wakes the dispatcher up when new deliveries are saved
+var webhookWakeUp = make(chan struct{}, 1)
+
+// SetWebhookClient sets the client sending the requests of the webhooks
+func SetWebhookClient(client HTTPClient) {
+	webhookClient = client
+}
+
+// SetWebhookDelivery sets the timeout of the deliveries, their number of attempts and the delay before the first retry,
+// replacing the client by one with that timeout
+func SetWebhookDelivery(timeout time.Duration, maxAttempts int, retryDelay time.Duration) {
+	webhookTimeout = timeout
+	webhookMaxAttempts = maxAttempts
+	webhookRetryDelay = retryDelay
+	webhookClient = &http.Client{Timeout: timeout}
+}
+
+// WebhookPayload is the JSON body sent to webhooks, Data depends on the event
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt string      `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+type PostPayload struct {
+	Id         int      `json:"id"`
+	Username   string   `json:"username"`
+	Title      string   `json:"title"`
+	Categories []string `json:"categories"`
+	Content    string   `json:"content"`
+	URL        string   `json:"url"`
+}
+
+type CommentPayload struct {
+	Id       int    `json:"id"`
+	PostId   int    `json:"postId"`
+	ParentId int    `json:"parentId"`
+	Username string `json:"username"`
+	Content  string `json:"content"`
+	URL      string `json:"url"`
+}
+
+type VotePayload struct {
+	PostId    int    `json:"postId"`
+	Username  string `json:"username"`
+	Vote      int    `json:"vote"`
+	UpVotes   int    `json:"upVotes"`
+	DownVotes int    `json:"downVotes"`
+	URL       string `json:"url"`
+}
+
+type ReportPayload struct {
+	Id        int    `json:"id"`
+	PostId    int    `json:"postId"`
+	CommentId int    `json:"commentId"`
+	Username  string `json:"username"`
+	Reason    string `json:"reason"`
+	URL       string `json:"url"`
+}
+
+type WebhooksPage struct {
+	User       User
+	Webhooks   []databaseAPI.Webhook
+	Events     []string
+	Categories []string
+}
+
+type DeliveriesPage struct {
+	User       User
+	Deliveries []databaseAPI.WebhookDelivery
+	WebhookId  int
+	Status     string
+}
+
+// fireWebhooks saves a delivery of an event of a user about a post for each active webhook subscribed to it. Webhooks
+// filtering categories only get the events about posts in one of them.
+func fireWebhooks(event string, username string, postId int, categories []string, data interface{}) {
+	webhooks, err := databaseAPI.GetWebhooks(database)
+	if err != nil {
+		logger.Error("webhooks failed", "event", event, "err", err)
+		return
+	}
+	now := time.Now()
+	payload, err := json.Marshal(WebhookPayload{Event: event, CreatedAt: now.UTC().Format(time.RFC3339), Data: data})
+	if err != nil {
+		logger.Error("webhooks failed", "event", event, "err", err)
+		return
+	}
+	saved := false
+	for _, webhook := range webhooks {
+		if !webhook.Active || !inArray(event, webhook.Events) || !matchesCategories(webhook.Categories, categories) {
+			continue
+		}
+		if _, err := databaseAPI.AddWebhookDelivery(database, webhook.Id, event, string(payload), username, postId, now); err != nil {
+			logger.Error("webhook delivery failed", "url", webhook.URL, "err", err)
+			continue
+		}
+		saved = true
+	}
+	if saved {
+		wakeUpWebhookDispatcher()
+	}
+}
+
+// wakeUpWebhookDispatcher makes the dispatcher look for due deliveries without waiting for its next poll
+func wakeUpWebhookDispatcher() {
+	select {
+	case webhookWakeUp <- struct{}{}:
+	default:
+	}
+}
+
+// matchesCategories returns true if a webhook doesn't filter categories, or if one of the categories is in its filter
+func matchesCategories(filter []string, categories []string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, category := range categories {
+		if inArray(category, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// fireVoteWebhooks sends the vote of a user on a post to the webhooks, vote is 0 when the vote has been removed
+func fireVoteWebhooks(postId int, username string, vote int) {
+	post, err := store.GetPost(strconv.Itoa(postId))
+	if err != nil {
+		logger.Error("vote webhooks failed", "post_id", postId, "err", err)
+		return
+	}
+	fireWebhooks(eventVoteCast, username, postId, post.Categories, VotePayload{
+		PostId:    postId,
+		Username:  username,
+		Vote:      vote,
+		UpVotes:   post.UpVotes,
+		DownVotes: post.DownVotes,
+		URL:       postURL(postId, 0),
+	})
+}
+
+// RunWebhookDispatcher delivers the webhooks until the context is canceled
+func RunWebhookDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		deliverDueWebhooks(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-webhookWakeUp:
+		}
+	}
+}
+
+// deliverDueWebhooks attempts the deliveries whose next attempt is due
+func deliverDueWebhooks(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := databaseAPI.GetDueWebhookDeliveries(database, time.Now(), webhookBatchSize)
+		if err != nil {
+			logger.Error("webhook deliveries failed", "err", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			attemptDelivery(ctx, delivery)
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// attemptDelivery sends a delivery to its webhook. A failed attempt is retried with an exponential backoff, and the
+// delivery goes to the dead letters after webhookMaxAttempts attempts.
+func attemptDelivery(ctx context.Context, delivery databaseAPI.WebhookDelivery) {
+	webhook, err := databaseAPI.GetWebhook(database, delivery.WebhookId)
+	found := !errors.Is(err, databaseAPI.ErrNotFound)
+	if err != nil && found {
+		return
+	}
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	if !found || !webhook.Active {
+		delivery.LastError = "webhook disabled"
+		delivery.Status = deliveryDead
+	} else {
+		delivery.LastStatusCode, err = postWebhook(ctx, webhook, delivery)
+		// an attempt interrupted by the shutdown of the server is attempted again at the next start
+		if ctx.Err() != nil {
+			return
+		}
+		now := time.Now()
+		switch {
+		case err != nil:
+			delivery.LastError = err.Error()
+		case delivery.LastStatusCode < 200 || delivery.LastStatusCode > 299:
+			delivery.LastError = "unexpected status " + strconv.Itoa(delivery.LastStatusCode)
+		default:
+			delivery.LastError = ""
+			delivery.Status = deliveryDelivered
+			delivery.DeliveredAt = databaseAPI.FormatTime(now)
+		}
+		if delivery.Status != deliveryDelivered {
+			if delivery.Attempts >= webhookMaxAttempts {
+				delivery.Status = deliveryDead
+			} else {
+				delivery.NextAttemptAt = databaseAPI.FormatTime(now.Add(retryDelay(delivery.Attempts)))
+			}
+		}
+	}
+	if err := databaseAPI.UpdateWebhookDelivery(database, delivery); err != nil {
+		logger.Error("webhook delivery failed", "delivery_id", delivery.Id, "err", err)
+		return
+	}
+	if delivery.Status == deliveryDead {
+		logAPI.Audit(ctx, logger, logAPI.WebhookDeadLettered{DeliveryId: delivery.Id, URL: webhook.URL, Error: delivery.LastError})
+	}
+}
+
+// retryDelay returns the delay before the next attempt of a delivery attempted a number of times, webhookRetryDelay
+// doubled at each attempt up to webhookMaxRetry
+func retryDelay(attempts int) time.Duration {
+	delay := webhookRetryDelay
+	for i := 1; i < attempts && delay < webhookMaxRetry; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxRetry {
+		return webhookMaxRetry
+	}
+	return delay
+}
+
+// postWebhook sends the payload of a delivery signed with the secret of its webhook and the time of the attempt, and
+// returns the status code
+func postWebhook(ctx context.Context, webhook databaseAPI.Webhook, delivery databaseAPI.WebhookDelivery) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, "POST", webhook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "FORUM-GO-Webhooks")
+	request.Header.Set("X-Forum-Event", delivery.Event)
+	request.Header.Set("X-Forum-Delivery", strconv.Itoa(delivery.Id))
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("X-Forum-Timestamp", timestamp)
+	request.Header.Set("X-Forum-Signature", "sha256="+signPayload(webhook.Secret, timestamp, delivery.Payload))
+	response, err := webhookClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, webhookMaxResponse))
+	return response.StatusCode, nil
+}
+
+// signPayload returns the hex encoded HMAC-SHA256 of the timestamp of an attempt, a dot and the payload, so that a
+// receiver refusing old timestamps can't be sent a captured request again
+func signPayload(secret string, timestamp string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookSecret returns a random secret to sign the payloads of a webhook
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// isValidWebhookURL returns true if the URL is an absolute http or https URL
+func isValidWebhookURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// DisplayWebhooks displays the webhooks to the admins
+func DisplayWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if !isAdmin(r) {
+		writeStatus(w, r, http.StatusForbidden)
+		return
+	}
+	payload := WebhooksPage{User: getCurrentUser(r), Events: webhookEvents}
+	var err error
+	if payload.Categories, err = store.GetCategories(); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if payload.Webhooks, err = databaseAPI.GetWebhooks(database); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	renderTemplate(w, r, "webhooks.html", payload)
+}
+
+// WebhooksApi creates, enables, disables or deletes a webhook depending on the action
+func WebhooksApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if !isAdmin(r) {
+		writeStatus(w, r, http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	username, err := sessionUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	action := r.FormValue("action")
+	if action == "create" {
+		webhook := databaseAPI.Webhook{
+			URL:        strings.TrimSpace(r.FormValue("url")),
+			Secret:     r.FormValue("secret"),
+			Events:     r.Form["events[]"],
+			Categories: r.Form["categories[]"],
+			Active:     true,
+			CreatedBy:  username,
+		}
+		if !isValidWebhookURL(webhook.URL) {
+			renderError(w, r, http.StatusBadRequest, "Invalid URL : "+webhook.URL)
+			return
+		}
+		if len(webhook.Events) == 0 {
+			renderError(w, r, http.StatusBadRequest, "Select at least one event")
+			return
+		}
+		for _, event := range webhook.Events {
+			if !inArray(event, webhookEvents) {
+				renderError(w, r, http.StatusBadRequest, "Invalid event : "+event)
+				return
+			}
+		}
+		categories, err := store.GetCategories()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for _, category := range webhook.Categories {
+			if !inArray(category, categories) {
+				renderError(w, r, http.StatusBadRequest, "Invalid category : "+category)
+				return
+			}
+		}
+		if webhook.Secret == "" {
+			var err error
+			if webhook.Secret, err = newWebhookSecret(); err != nil {
+				writeError(w, r, err)
+				return
+			}
+		}
+		if _, err := databaseAPI.AddWebhook(database, webhook, time.Now()); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		logAPI.Audit(r.Context(), logger, logAPI.WebhookCreated{Username: username, URL: webhook.URL})
+		http.Redirect(w, r, "/admin/webhooks", http.StatusFound)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid webhook")
+		return
+	}
+	switch action {
+	case "enable":
+		err = databaseAPI.SetWebhookActive(database, id, true)
+	case "disable":
+		err = databaseAPI.SetWebhookActive(database, id, false)
+	case "delete":
+		err = databaseAPI.DeleteWebhook(database, id)
+	default:
+		renderError(w, r, http.StatusBadRequest, "Invalid action")
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	logAPI.Audit(r.Context(), logger, logAPI.WebhookChanged{Username: username, WebhookId: id, Action: action})
+	http.Redirect(w, r, "/admin/webhooks", http.StatusFound)
+}
+
+// DisplayWebhookDeliveries displays the delivery log to the admins, only the deliveries of a webhook with webhook=
+// and the ones with a status with status=, status=dead shows the dead letters
+func DisplayWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if !isAdmin(r) {
+		writeStatus(w, r, http.StatusForbidden)
+		return
+	}
+	payload := DeliveriesPage{User: getCurrentUser(r), Status: r.URL.Query().Get("status")}
+	payload.WebhookId, _ = strconv.Atoi(r.URL.Query().Get("webhook"))
+	var err error
+	if payload.Deliveries, err = databaseAPI.GetWebhookDeliveries(database, payload.WebhookId, payload.Status, webhookLogLength); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	renderTemplate(w, r, "deliveries.html", payload)
+}
+
+// RetryWebhookDeliveryApi sends a dead letter again, with a new set of retries
+func RetryWebhookDeliveryApi(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeStatus(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+	if !isLoggedIn(r) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+	if !isAdmin(r) {
+		writeStatus(w, r, http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeFormError(w, r, err)
+		return
+	}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, "Invalid delivery")
+		return
+	}
+	if err := databaseAPI.RetryWebhookDelivery(database, id, time.Now()); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	wakeUpWebhookDispatcher()
+	http.Redirect(w, r, "/admin/webhooks/deliveries?status="+deliveryDead, http.StatusFound)
+}
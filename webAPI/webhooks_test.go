@@ -0,0 +1,223 @@
+package webAPI
+
+import (
+	"FORUM-GO/databaseAPI"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// webhookReceiver records the requests sent to a test webhook and answers them with its status
+type webhookReceiver struct {
+	lock     sync.Mutex
+	status   int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   string
+}
+
+func (receiver *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	receiver.requests = append(receiver.requests, receivedWebhook{header: r.Header.Clone(), body: string(body)})
+	w.WriteHeader(receiver.status)
+}
+
+func (receiver *webhookReceiver) received() []receivedWebhook {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	return append([]receivedWebhook(nil), receiver.requests...)
+}
+
+// setupWebhooks points the webhooks at a test server answering with status, with a new database holding a webhook and
+// a delivery to it, and returns the receiver, the webhook and the delivery
+func setupWebhooks(t *testing.T, status int) (*webhookReceiver, databaseAPI.Webhook, databaseAPI.WebhookDelivery) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := databaseAPI.CreateWebhookTables(db); err != nil {
+		t.Fatal(err)
+	}
+	receiver := &webhookReceiver{status: status}
+	server := httptest.NewServer(receiver)
+	previousDatabase, previousLogger, previousClient := database, logger, webhookClient
+	previousAttempts, previousDelay := webhookMaxAttempts, webhookRetryDelay
+	t.Cleanup(func() {
+		server.Close()
+		db.Close()
+		database, logger, webhookClient = previousDatabase, previousLogger, previousClient
+		webhookMaxAttempts, webhookRetryDelay = previousAttempts, previousDelay
+	})
+	SetDatabase(db)
+	SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	SetWebhookClient(server.Client())
+	webhookMaxAttempts, webhookRetryDelay = 3, time.Minute
+
+	webhook := databaseAPI.Webhook{URL: server.URL, Secret: "secret", Events: []string{eventPostCreated}, Active: true, CreatedBy: "admin"}
+	if webhook.Id, err = databaseAPI.AddWebhook(db, webhook, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := databaseAPI.AddWebhookDelivery(db, webhook.Id, eventPostCreated, `{"event":"post.created"}`, "", 0, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	return receiver, webhook, getDelivery(t)
+}
+
+// getDelivery returns the only delivery of the database
+func getDelivery(t *testing.T) databaseAPI.WebhookDelivery {
+	t.Helper()
+	deliveries, err := databaseAPI.GetWebhookDeliveries(database, 0, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	return deliveries[0]
+}
+
+func TestWebhookSignature(t *testing.T) {
+	receiver, webhook, _ := setupWebhooks(t, http.StatusNoContent)
+	before := time.Now().Unix()
+	deliverDueWebhooks(context.Background())
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	request := requests[0]
+	if event := request.header.Get("X-Forum-Event"); event != eventPostCreated {
+		t.Errorf("X-Forum-Event = %q, want %q", event, eventPostCreated)
+	}
+	timestamp := request.header.Get("X-Forum-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || seconds < before || seconds > time.Now().Unix() {
+		t.Errorf("X-Forum-Timestamp = %q, want the time of the attempt", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write([]byte(timestamp + "." + request.body))
+	if signature, want := request.header.Get("X-Forum-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("X-Forum-Signature = %q, want %q", signature, want)
+	}
+
+	delivery := getDelivery(t)
+	if delivery.Status != deliveryDelivered || delivery.Attempts != 1 || delivery.LastStatusCode != http.StatusNoContent {
+		t.Errorf("delivery is %s after %d attempts with status %d, want delivered after 1 attempt with status 204", delivery.Status, delivery.Attempts, delivery.LastStatusCode)
+	}
+	if delivery.DeliveredAt == "" {
+		t.Error("the delivery has no delivery time")
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	receiver, _, delivery := setupWebhooks(t, http.StatusInternalServerError)
+	for attempt := 1; attempt < webhookMaxAttempts; attempt++ {
+		start := time.Now()
+		attemptDelivery(context.Background(), delivery)
+		delivery = getDelivery(t)
+		if delivery.Status != "pending" || delivery.Attempts != attempt {
+			t.Fatalf("delivery is %s after %d attempts, want pending after %d", delivery.Status, delivery.Attempts, attempt)
+		}
+		if delivery.LastStatusCode != http.StatusInternalServerError || delivery.LastError != "unexpected status 500" {
+			t.Errorf("attempt %d: got status %d and error %q", attempt, delivery.LastStatusCode, delivery.LastError)
+		}
+		// the delay doubles at each attempt
+		delay := webhookRetryDelay << (attempt - 1)
+		next, err := databaseAPI.ParseTime(delivery.NextAttemptAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if earliest, latest := start.Add(delay).Truncate(time.Second), time.Now().Add(delay); next.Before(earliest) || next.After(latest) {
+			t.Errorf("attempt %d: next attempt at %v, want %v later", attempt, next, delay)
+		}
+		// the retry isn't due before its delay
+		due, err := databaseAPI.GetDueWebhookDeliveries(database, time.Now(), webhookBatchSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(due) != 0 {
+			t.Errorf("attempt %d: the retry is due before its delay", attempt)
+		}
+	}
+
+	attemptDelivery(context.Background(), delivery)
+	delivery = getDelivery(t)
+	if delivery.Status != deliveryDead || delivery.Attempts != webhookMaxAttempts {
+		t.Errorf("delivery is %s after %d attempts, want dead after %d", delivery.Status, delivery.Attempts, webhookMaxAttempts)
+	}
+	if requests := receiver.received(); len(requests) != webhookMaxAttempts {
+		t.Errorf("got %d requests, want %d", len(requests), webhookMaxAttempts)
+	}
+	dead, err := databaseAPI.GetWebhookDeliveries(database, 0, deliveryDead, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 {
+		t.Errorf("got %d dead letters, want 1", len(dead))
+	}
+
+	// a dead letter sent again gets a new set of retries
+	if err := databaseAPI.RetryWebhookDelivery(database, delivery.Id, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	receiver.lock.Lock()
+	receiver.status = http.StatusOK
+	receiver.lock.Unlock()
+	deliverDueWebhooks(context.Background())
+	if delivery = getDelivery(t); delivery.Status != deliveryDelivered || delivery.Attempts != 1 {
+		t.Errorf("retried delivery is %s after %d attempts, want delivered after 1", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{"first retry", 1, webhookRetryDelay},
+		{"doubled", 3, webhookRetryDelay * 4},
+		{"capped", 20, webhookMaxRetry},
+		{"no overflow", 100, webhookMaxRetry},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := retryDelay(test.attempts); got != test.want {
+				t.Errorf("retryDelay(%d) = %v, want %v", test.attempts, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWebhookDisabled(t *testing.T) {
+	receiver, webhook, delivery := setupWebhooks(t, http.StatusOK)
+	if err := databaseAPI.SetWebhookActive(database, webhook.Id, false); err != nil {
+		t.Fatal(err)
+	}
+	attemptDelivery(context.Background(), delivery)
+	if delivery = getDelivery(t); delivery.Status != deliveryDead || delivery.LastError != "webhook disabled" {
+		t.Errorf("delivery is %s with error %q, want dead with webhook disabled", delivery.Status, delivery.LastError)
+	}
+	if requests := receiver.received(); len(requests) != 0 {
+		t.Errorf("got %d requests to a disabled webhook, want 0", len(requests))
+	}
+}